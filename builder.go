@@ -0,0 +1,575 @@
+package mph
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/instabid/bloom"
+	"golang.org/x/text/unicode/norm"
+)
+
+// A Builder builds Tables using a particular configuration. A Builder is
+// created with NewBuilder and may be used to build any number of Tables.
+type Builder struct {
+	loadFactor float32
+	fpProb     float64
+	hasher     Hasher // nil means Murmur3
+	reduction  reduction
+	maxLineLen int
+
+	level0Ratio     float64
+	maxSeedAttempts uint32
+	fallback        bool
+	narrowSeeds     bool
+	sortedUnique    bool
+	dedup           bool
+	bucketSeed      uint32
+	folding         folding
+	progress        func(done, total int)
+	report          *CollisionReport
+	noBloom         bool
+	filter          *bloom.Filter // a filter supplied using WithFilter
+	fingerprintBits int           // the width set by WithFingerprint or WithSlotFingerprint, or 0
+	hashBits        int           // the width set by WithHashBits, or 0 for 32
+	memoryLimit     int           // the limit set by WithMemoryLimit, or 0
+	maxKeyLen       int           // the limit set by WithMaxKeyLen, or 0
+	expectedKeys    int           // the hint set by WithExpectedKeys, or 0
+	hashKey         *keyedHasher  // the salt set by WithHashKey, or nil
+}
+
+// An Option configures a Builder.
+type Option func(*Builder)
+
+// NewBuilder returns a Builder configured by opts. By default, a Builder uses
+// a load factor of 1.0, a false positive rate of 0.01, and Murmur3.
+func NewBuilder(opts ...Option) *Builder {
+	b := &Builder{
+		loadFactor: 1.0,
+		fpProb:     0.01,
+		maxLineLen: bufio.MaxScanTokenSize,
+
+		level0Ratio:     defaultLevel0Ratio,
+		maxSeedAttempts: maxSeedAttempts,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.hashKey != nil {
+		k := *b.hashKey
+		k.h = b.hasher
+		b.hasher = k
+	}
+	return b
+}
+
+// WithLoadFactor sets the initial ratio of keys to level1 slots, which must
+// be in (0, 1]. At 1, the default, the table is minimal: it has a slot for
+// each key. Lower load factors give perfect but not minimal tables, such as
+// 0.5 for twice as many slots as keys, whose seeds are much quicker to find,
+// which suits tables that are rebuilt often: at 0.9, a build tries about an
+// eighth as many seeds as at 1 and takes about a third of the time, for about
+// 10% more memory. Lookups work as in minimal tables, since empty slots are
+// only reached by keys which are not in the table, which the filter or
+// fingerprints reject. If a table cannot be built at this load factor,
+// progressively lower load factors are tried.
+func WithLoadFactor(loadFactor float32) Option {
+	return func(b *Builder) { b.loadFactor = loadFactor }
+}
+
+// WithFalsePositiveRate sets the false positive probability of the bloom
+// filter used to detect keys which are not in the table. It must be strictly
+// between 0 and 1.
+func WithFalsePositiveRate(fpProb float64) Option {
+	return func(b *Builder) { b.fpProb = fpProb }
+}
+
+// WithHasher sets the hash function used to place keys. Tables built with a
+// given Hasher use it for lookups, and can only be unmarshaled if it has been
+// registered with RegisterHasher. Building fails with an error wrapping
+// ErrHasherID if h's ID is reserved or belongs to another registered Hasher.
+func WithHasher(h Hasher) Option {
+	return func(b *Builder) {
+		if _, ok := h.(Murmur3); ok {
+			h = nil
+		}
+		b.hasher = h
+	}
+}
+
+// WithMaxSeedAttempts sets the number of seeds tried for each bucket before
+// the table is rebuilt at a lower load factor. A low limit makes builds which
+// would need a long search fail fast, with an error wrapping ErrSeedExhausted
+// once the load factor cannot be lowered further. The default is 100000000;
+// n of 0 selects the default.
+func WithMaxSeedAttempts(n uint32) Option {
+	return func(b *Builder) {
+		if n == 0 {
+			n = maxSeedAttempts
+		}
+		b.maxSeedAttempts = n
+	}
+}
+
+// WithoutBloom builds tables without a bloom filter. Such tables are smaller
+// and cheaper to query, but cannot detect keys which are not in them: Lookup
+// reports every key as found. They suit sets where only keys known to be in
+// the table are looked up. Their encoding simply omits the filter.
+func WithoutBloom() Option {
+	return func(b *Builder) { b.noBloom, b.filter, b.fingerprintBits = true, nil, 0 }
+}
+
+// WithFingerprint builds tables which detect keys which are not in them by
+// storing a fingerprint of the key in each level1 slot, the given number of
+// bits, 8 or 16, of a second hash, instead of a bloom filter. Lookup then
+// compares the fingerprint of the string looked up with that in its slot, so
+// that a string which is not in the table is reported as found with a
+// probability of 2^-bits, which FalsePositiveRate returns. Unlike a filter,
+// this takes exactly bits per slot and costs no memory accesses beyond the
+// slot's. Table64 does not support fingerprints.
+func WithFingerprint(bits int) Option {
+	return func(b *Builder) { b.noBloom, b.filter, b.fingerprintBits = true, nil, bits }
+}
+
+// WithSlotFingerprint is like WithFingerprint but keeps the bloom filter,
+// so that the fingerprints augment it rather than replace it. Lookup first
+// compares the fingerprints, which rejects most strings which are not in the
+// table without touching the filter, and reports a string found only if the
+// filter also contains it. The false positive rate is the product of the
+// filter's and 2^-bits, which makes lookups nearly exact without the memory
+// of BuildWithKeys.
+func WithSlotFingerprint(bits int) Option {
+	return func(b *Builder) { b.noBloom, b.fingerprintBits = false, bits }
+}
+
+// WithFilter builds tables which use f as their bloom filter instead of
+// building one. The tables retain f, so it must not be modified in ways which
+// remove keys. Building checks that f contains every key, returning an error
+// wrapping ErrFilterMismatch otherwise, which is cheaper than adding the keys
+// to a new filter. Since f's false positive rate is not known, the tables'
+// FalsePositiveRate is 0.
+func WithFilter(f *bloom.Filter) Option {
+	return func(b *Builder) { b.noBloom, b.filter, b.fingerprintBits = false, f, 0 }
+}
+
+// WithPowerOfTwoSizes rounds the lengths of the level arrays up to powers of
+// two, so that lookups can reduce hashes to indices with a mask rather than a
+// division. This makes lookups faster at the cost of up to twice as many
+// level1 slots as the load factor calls for.
+func WithPowerOfTwoSizes() Option {
+	return func(b *Builder) { b.reduction = reduceMask }
+}
+
+// WithFastRange makes lookups reduce hashes to indices by multiplying them by
+// the length of the level array and keeping the high bits, which avoids a
+// division without constraining the lengths as WithPowerOfTwoSizes does. Of
+// the two options, the last one given takes effect.
+func WithFastRange() Option {
+	return func(b *Builder) { b.reduction = reduceRange }
+}
+
+// WithProbingFallback makes a bucket whose seed search is exhausted fall back
+// to a second search, which places its keys by double hashing, before the
+// table is rebuilt at a lower load factor. This helps with clustered keys
+// whose hashes stay close together whatever the seed. Lookups into the
+// resulting tables test for such buckets, so they are marginally slower, and
+// at most 1<<31 seeds and probes are tried for each bucket.
+func WithProbingFallback() Option {
+	return func(b *Builder) { b.fallback = true }
+}
+
+// WithNarrowSeeds makes a table whose seeds all fit in 16 bits store its
+// level0 array as uint16s, halving its size in memory and in the encoding.
+// Tables with a larger seed, or with buckets placed by the probing fallback,
+// keep 32-bit seeds. Table64 ignores this option.
+func WithNarrowSeeds() Option {
+	return func(b *Builder) { b.narrowSeeds = true }
+}
+
+// WithHashBits sets the width, 32 or 64, of the hashes which place keys in
+// level1 slots, 32 by default. With 64, a key's slot is found by reducing a
+// 64-bit hash computed, as Table64 does, from two 32-bit hashes with
+// complementary seeds. Reducing 32-bit hashes to n slots favors some slots
+// over others once n is a noticeable fraction of 2^32, which 64-bit hashes
+// avoid, but they cost two hashes of each key when it is placed or looked
+// up. For a few million keys, the placement is no easier with 64 bits and the
+// build is slower, as BenchmarkBuild_hashBits shows. The width is recorded in
+// the table. The hashes which assign keys to level0 buckets, and those of
+// WithProbingFallback, stay 32 bits. Table64 always uses 64-bit hashes.
+func WithHashBits(bits int) Option {
+	return func(b *Builder) { b.hashBits = bits }
+}
+
+// wideHash reports whether b places keys using 64-bit hashes.
+func (b *Builder) wideHash() bool {
+	return b.hashBits == 64
+}
+
+// WithBucketSeed sets the seed of the hash which assigns keys to level0
+// buckets, 0 by default. Different seeds give different but equally valid
+// tables for the same keys, such as to compare the quality of several builds
+// or to vary a build deterministically. The seed is recorded in the table.
+func WithBucketSeed(seed uint32) Option {
+	return func(b *Builder) { b.bucketSeed = seed }
+}
+
+// WithSortedUniqueKeys promises that the keys are in strictly increasing
+// order, as from a sorted index, so that the builder may skip checking them
+// for duplicates. The order is that of the keys after any WithCaseFold or
+// WithNormalization, which may make distinct keys equal. If the promise is
+// broken, the behavior is undefined: the build may fail after a long seed
+// search or give a table which returns wrong indices. Building with the
+// mphdebug build tag checks the promise, returning a *DuplicateKeyError for
+// a repeated key and an error for keys out of order.
+func WithSortedUniqueKeys() Option {
+	return func(b *Builder) { b.sortedUnique = true }
+}
+
+// WithDedup makes the builder drop repeated keys, as DedupKeys does, rather
+// than report them with a *DuplicateKeyError, so that each key's index is the
+// number of distinct keys which precede its first occurrence. Keys made equal
+// by WithCaseFold or WithNormalization are repeats. BuildWithIndices ignores
+// it, since the indices of such keys may differ.
+func WithDedup() Option {
+	return func(b *Builder) { b.dedup = true }
+}
+
+// WithCaseFold makes the table ignore case: keys are lowercased before they
+// are hashed and added to the bloom filter, and so are the strings looked up,
+// so that Lookup("FOO") finds the key "foo". Keys which differ only in case
+// are duplicates. Lowercasing is that of strings.ToLower, which maps each
+// rune by itself using the Unicode simple case mappings: "ÉCOLE" matches
+// "école" and "ΣΟΦΙΑ" matches "σοφια", but mappings which change the number
+// of runes or depend on context are not applied, so "STRASSE" does not match
+// "straße" and a final "ς" does not match "σ". A filter supplied using
+// WithFilter must contain the lowercased keys.
+func WithCaseFold() Option {
+	return func(b *Builder) { b.folding.caseFold = true }
+}
+
+// WithNormalization makes the table normalize keys, and the strings looked
+// up, to the given Unicode normal form before hashing them, so that strings
+// which differ only in their normal form, such as a precomposed "é" and an
+// "e" followed by a combining acute accent, are the same key. Keys which
+// normalize to the same string are duplicates. With WithCaseFold too,
+// strings are normalized before they are lowercased. A filter supplied using
+// WithFilter must contain the normalized keys.
+func WithNormalization(form norm.Form) Option {
+	return func(b *Builder) {
+		b.folding.norm = maxNorm + 1 // rejected by validate
+		if form >= norm.NFC && form <= norm.NFKD {
+			b.folding.norm = byte(form) + 1
+		}
+	}
+}
+
+// WithProgress sets a function which is called as the keys are placed, after
+// every 1024 level0 buckets and after the last one, with the number of
+// buckets placed so far and the total number of non-empty buckets. If the
+// table is rebuilt at a lower load factor, the count starts again, possibly
+// with a different total. The function is called on the goroutine building
+// the table.
+func WithProgress(f func(done, total int)) Option {
+	return func(b *Builder) { b.progress = f }
+}
+
+// WithCollisionReport makes BuildWithIndices fill in *r with the keys which
+// it gives the same index, which usually points to a problem with the source
+// of the indices. Other builds, whose keys all have distinct indices, leave
+// *r empty. The Builder should not be used concurrently with this option.
+func WithCollisionReport(r *CollisionReport) Option {
+	return func(b *Builder) { b.report = r }
+}
+
+// seedLimit returns the number of seeds, and fallback probes, tried for each
+// bucket.
+func (b *Builder) seedLimit() uint32 {
+	if b.fallback {
+		return min(b.maxSeedAttempts, fallbackBit)
+	}
+	return b.maxSeedAttempts
+}
+
+// WithLevel0Ratio sets the ratio of level1 slots to level0 buckets, which is
+// 4 by default. Lower ratios mean more, smaller buckets, which makes seeds
+// quicker to find at the cost of a larger level0 array. The ratio must be
+// positive.
+func WithLevel0Ratio(r float64) Option {
+	return func(b *Builder) { b.level0Ratio = r }
+}
+
+// WithMemoryLimit limits the memory which a build allocates for the level
+// arrays of the table, and for the scratch space needed to fill them, to n
+// bytes. The sizes are computed before anything is allocated, so a build
+// which would exceed the limit, such as one at a mistakenly tiny load factor,
+// fails with an error wrapping ErrMemoryLimit rather than exhausting memory.
+// Since the load factor is lowered if the seed search fails, a build which
+// starts within the limit may still exceed it. A limit of 0, the default,
+// means no limit.
+func WithMemoryLimit(n int) Option {
+	return func(b *Builder) { b.memoryLimit = n }
+}
+
+// checkMemory returns an error wrapping ErrMemoryLimit if level arrays of the
+// given lengths, whose level1 entries are level1Width bytes, exceed b's limit.
+// Each level1 slot also takes a byte of scratch space while placing keys.
+func (b *Builder) checkMemory(level0Len, level1Len, level1Width int) error {
+	if b.memoryLimit <= 0 {
+		return nil
+	}
+	need := uint64(level0Len)*bphw + uint64(level1Len)*uint64(level1Width+1+b.fingerprintBits/8)
+	if need > uint64(b.memoryLimit) {
+		return fmt.Errorf("%w: %d level0 buckets and %d level1 slots need %d bytes, more than %d",
+			ErrMemoryLimit, level0Len, level1Len, need, b.memoryLimit)
+	}
+	return nil
+}
+
+// WithMaxKeyLen makes builds reject keys longer than n bytes with a
+// *KeyTooLongError, rather than spend time hashing them for every seed tried
+// and every filter operation. The limit applies to the keys as given, before
+// any WithCaseFold or WithNormalization. A limit of 0, the default, means no
+// limit.
+func WithMaxKeyLen(n int) Option {
+	return func(b *Builder) { b.maxKeyLen = n }
+}
+
+// checkKeyLens returns a *KeyTooLongError for the first of keys which is
+// longer than b's limit.
+func (b *Builder) checkKeyLens(keys []string) error {
+	if b.maxKeyLen <= 0 {
+		return nil
+	}
+	for i, key := range keys {
+		if len(key) > b.maxKeyLen {
+			return &KeyTooLongError{Index: i, Len: len(key), Max: b.maxKeyLen}
+		}
+	}
+	return nil
+}
+
+// WithExpectedKeys tells BuildFromReader and BuildFromChan to expect about n
+// distinct keys, so that they allocate room for them at once rather than
+// growing their buffers as keys arrive. The hint only affects allocations;
+// any number of keys may still be read.
+func WithExpectedKeys(n int) Option {
+	return func(b *Builder) { b.expectedKeys = n }
+}
+
+// WithMaxLineLen sets the length of the longest line, including the
+// terminating newline, that BuildFromReader accepts. The default is
+// bufio.MaxScanTokenSize.
+func WithMaxLineLen(n int) Option {
+	return func(b *Builder) { b.maxLineLen = n }
+}
+
+// Build builds a Table from keys.
+func (b *Builder) Build(keys []string) (*Table, error) {
+	return b.BuildContext(context.Background(), keys)
+}
+
+// BuildContext is like Build but stops early, returning ctx.Err(), if ctx is
+// done before the table is built.
+func (b *Builder) BuildContext(ctx context.Context, keys []string) (*Table, error) {
+	if err := checkKeyCount(uint64(len(keys))); err != nil {
+		return nil, err
+	}
+	return build(ctx, b, keys, b.buildInternal)
+}
+
+// Build64 builds a Table64 from keys.
+func (b *Builder) Build64(keys []string) (*Table64, error) {
+	if b.fingerprintBits != 0 {
+		return nil, errors.New("mph: Table64 does not support fingerprints")
+	}
+	return build(context.Background(), b, keys, b.buildInternal64)
+}
+
+// BuildWithIndices builds a Table which maps each key of m to its value, like
+// the function BuildWithIndices. If b was configured WithCollisionReport, the
+// report lists the keys which were given the same index.
+func (b *Builder) BuildWithIndices(m map[string]uint32) (*Table, error) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	// Sort the keys so that the same map always gives the same table.
+	sort.Strings(keys)
+	bb := *b
+	bb.dedup = false
+	t, err := bb.Build(keys)
+	if err != nil {
+		return nil, err
+	}
+	for i, n := range t.level1 {
+		t.level1[i] = m[keys[n]]
+	}
+	t.ids = true
+	if b.report != nil {
+		b.report.fill(keys, m)
+	}
+	return t, nil
+}
+
+// A buildFunc builds a table from keys at the given load factor. spent is the
+// number of seed attempts made at the load factors tried before, which
+// Tables record. It returns the table and the buildStats of the build,
+// whose totalSeedAttempts include spent. If the seed search is exhausted it
+// returns nil and no error, with stats recording how far the search got, and
+// if ctx is done, the error of ctx.
+type buildFunc[T any] func(ctx context.Context, keys []string, loadFactor float32, filter *bloom.Filter, spent uint64) (*T, buildStats, error)
+
+// build creates the bloom filter for keys and calls buildFn, reducing the
+// load factor until it succeeds.
+func build[T any](ctx context.Context, b *Builder, keys []string, buildFn buildFunc[T]) (*T, error) {
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	if b.report != nil {
+		*b.report = CollisionReport{}
+	}
+	if err := b.checkKeyLens(keys); err != nil {
+		return nil, err
+	}
+	keys = b.folding.applyAll(keys)
+	if b.dedup {
+		keys = DedupKeys(keys)
+	} else if !b.sortedUnique {
+		if err := b.checkDuplicates(keys); err != nil {
+			return nil, err
+		}
+	} else if debugChecks {
+		if err := checkSorted(keys); err != nil {
+			return nil, err
+		}
+	}
+	filter := b.filter
+	if filter != nil {
+		for i, key := range keys {
+			if !filter.Has(key) {
+				return nil, fmt.Errorf("%w: key %q at index %d is missing", ErrFilterMismatch, key, i)
+			}
+		}
+	} else if !b.noBloom {
+		// An empty key set gets a filter sized for a single key to
+		// which nothing is added, so that every lookup misses.
+		filter = bloom.New(max(len(keys), 1), b.fpProb)
+		for _, key := range keys {
+			filter.Add(key)
+		}
+	}
+	loadFactor := b.loadFactor
+	var spent uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		table, stats, err := buildFn(ctx, keys, loadFactor, filter, spent)
+		if table != nil || err != nil {
+			return table, err
+		}
+		spent = stats.totalSeedAttempts
+		if loadFactor*0.9 < 0.1 {
+			return nil, &BuildError{
+				LoadFactor:        loadFactor,
+				MaxBucketSize:     stats.maxBucketSize,
+				PlacedBuckets:     stats.placedBuckets,
+				Buckets:           stats.numBuckets,
+				StuckBucketSize:   stats.stuckBucketSize,
+				TotalSeedAttempts: spent,
+			}
+		}
+		loadFactor *= 0.9
+	}
+}
+
+// filterFPProb returns the false positive rate of the filters of the tables
+// which b builds, or 0 if they have none or it is unknown.
+func (b *Builder) filterFPProb() float64 {
+	if b.noBloom || b.filter != nil {
+		return 0
+	}
+	return b.fpProb
+}
+
+// validate reports whether b's load factor and false positive rate are
+// usable. The NaN checks rely on every comparison with NaN being false.
+func (b *Builder) validate() error {
+	if !(b.loadFactor > 0 && b.loadFactor <= 1) {
+		return fmt.Errorf("%w: %v", ErrInvalidLoadFactor, b.loadFactor)
+	}
+	if !(b.fpProb > 0 && b.fpProb < 1) {
+		return fmt.Errorf("%w: %v", ErrInvalidFalsePositiveRate, b.fpProb)
+	}
+	if !(b.level0Ratio > 0) {
+		return fmt.Errorf("%w: %v", ErrInvalidLevel0Ratio, b.level0Ratio)
+	}
+	if b.fingerprintBits != 0 && b.fingerprintBits != 8 && b.fingerprintBits != 16 {
+		return fmt.Errorf("%w: %d", ErrInvalidFingerprintBits, b.fingerprintBits)
+	}
+	if b.hashBits != 0 && b.hashBits != 32 && b.hashBits != 64 {
+		return fmt.Errorf("%w: %d", ErrInvalidHashBits, b.hashBits)
+	}
+	if b.folding.norm > maxNorm {
+		return errors.New("mph: unknown normalization form")
+	}
+	return checkHasher(b.hasher)
+}
+
+// checkDuplicates returns a *DuplicateKeyError for the first key which
+// repeats an earlier one. Since equal keys fall into the same bucket, only
+// keys within each bucket need to be compared.
+func (b *Builder) checkDuplicates(keys []string) error {
+	s := getScratch()
+	defer putScratch(s)
+	dup := -1
+	for _, bucket := range b.bucketize(keys, max(len(keys)/4, 1), reduceMod, s) {
+		if i := firstDuplicate(keys, bucket.vals, s.seen); i >= 0 && (dup < 0 || i < dup) {
+			dup = i
+		}
+	}
+	if dup >= 0 {
+		return &DuplicateKeyError{Key: keys[dup], Index: dup}
+	}
+	return nil
+}
+
+// checkSorted returns a *DuplicateKeyError for the first key which equals the
+// one before it, or an error for the first which is less than it.
+func checkSorted(keys []string) error {
+	for i := 1; i < len(keys); i++ {
+		if keys[i] == keys[i-1] {
+			return &DuplicateKeyError{Key: keys[i], Index: i}
+		}
+		if keys[i] < keys[i-1] {
+			return fmt.Errorf("mph: key %q at index %d is out of order", keys[i], i)
+		}
+	}
+	return nil
+}
+
+// firstDuplicate returns the first of the indices vals, which are in
+// increasing order, whose key equals that of an earlier one, or -1 if there
+// is none. It uses seen as scratch space for large buckets.
+func firstDuplicate(keys []string, vals []int, seen map[string]struct{}) int {
+	if len(vals) <= 8 {
+		for j, i := range vals {
+			for _, k := range vals[:j] {
+				if keys[k] == keys[i] {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+	clear(seen)
+	for _, i := range vals {
+		if _, ok := seen[keys[i]]; ok {
+			return i
+		}
+		seen[keys[i]] = struct{}{}
+	}
+	return -1
+}
@@ -0,0 +1,667 @@
+package mph
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/instabid/bloom"
+)
+
+func TestBuilder(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux", "a", "b", "c"}
+	want, err := Build(keys, 0.8, 1e-6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBuilder(WithLoadFactor(0.8), WithFalsePositiveRate(1e-6), WithMaxSeedAttempts(1e6))
+	got, err := b.Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("Builder.Build: got a different table than Build with the same parameters")
+	}
+}
+
+func TestBuilder_withoutBloom(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	table, err := NewBuilder(WithoutBloom()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.filter != nil {
+		t.Error("WithoutBloom: table has a bloom filter")
+	}
+	for i, key := range keys {
+		if n, ok := table.Lookup(key); !ok || n != uint32(i) {
+			t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+	if _, ok := table.Lookup("absent"); !ok {
+		t.Error("Lookup(absent): got ok=false; want true without a bloom filter")
+	}
+}
+
+func TestBuilder_maxSeedAttempts(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := NewBuilder().Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.level1Len != len(keys) {
+		t.Fatalf("Build: got %d level1 slots; want %d", table.level1Len, len(keys))
+	}
+	// With only a few seeds per bucket, placing the last keys at a load
+	// factor of 1 fails, so the build falls back to lower load factors.
+	table, err = NewBuilder(WithMaxSeedAttempts(4)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.level1Len <= len(keys) {
+		t.Errorf("Build with 4 seed attempts: got %d level1 slots; want more than %d",
+			table.level1Len, len(keys))
+	}
+	for i, key := range keys {
+		if n, ok := table.Lookup(key); !ok || n != uint32(i) {
+			t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+	// But a single seed per bucket can't place two keys which collide
+	// under it at any load factor.
+	_, err = NewBuilder(WithMaxSeedAttempts(1)).Build(keys)
+	if !errors.Is(err, ErrSeedExhausted) {
+		t.Errorf("Build with 1 seed attempt: got err=%v; want one wrapping %v", err, ErrSeedExhausted)
+	}
+}
+
+func TestBuilder_withoutBloomMarshal(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	b := NewBuilder(WithoutBloom())
+	table, err := b.Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := parseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.bloomLen != 0 {
+		t.Errorf("MarshalBinary: got a %d-byte bloom filter; want none", h.bloomLen)
+	}
+	var decoded, read Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range []*Table{&decoded, &read} {
+		if tbl.filter != nil {
+			t.Error("decoded table has a bloom filter")
+		}
+		for i, key := range keys {
+			if n, ok := tbl.Lookup(key); !ok || n != uint32(i) {
+				t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+		if _, ok := tbl.Lookup("absent"); !ok {
+			t.Error("Lookup(absent): got ok=false; want true without a bloom filter")
+		}
+	}
+
+	table64, err := b.Build64(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = table64.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	var decoded64 Table64
+	if err := decoded64.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded64.filter != nil {
+		t.Error("decoded Table64 has a bloom filter")
+	}
+	for i, key := range keys {
+		if n, ok := decoded64.Lookup(key); !ok || n != uint64(i) {
+			t.Errorf("Table64.Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+}
+
+func TestBuilder_withFilter(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	f := bloom.New(len(keys), 0.01)
+	for _, key := range keys {
+		f.Add(key)
+	}
+	table, err := NewBuilder(WithFilter(f)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Filter() != f {
+		t.Errorf("Filter: got %p; want the supplied filter %p", table.Filter(), f)
+	}
+	want, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range append(keys, "absent", "quuux") {
+		gotN, gotOK := table.Lookup(key)
+		wantN, wantOK := want.Lookup(key)
+		if gotN != wantN || gotOK != wantOK {
+			t.Errorf("Lookup(%s): got (%d, %t); want (%d, %t)", key, gotN, gotOK, wantN, wantOK)
+		}
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		if n, ok := decoded.Lookup(key); !ok || n != uint32(i) {
+			t.Errorf("Lookup(%s) after UnmarshalBinary: got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+
+	_, err = NewBuilder(WithFilter(f)).Build(append(keys, "missing"))
+	if !errors.Is(err, ErrFilterMismatch) {
+		t.Errorf("Build with a filter missing a key: got err=%v; want one wrapping %v",
+			err, ErrFilterMismatch)
+	}
+}
+
+func TestBuilder_level0Ratio(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, tt := range []struct {
+		ratio     float64
+		level0Len int
+	}{
+		{1, 1000},
+		{2.5, 400},
+		{4, 250},
+		{8, 125},
+	} {
+		table, err := NewBuilder(WithLevel0Ratio(tt.ratio)).Build(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table.level0Len != tt.level0Len {
+			t.Errorf("WithLevel0Ratio(%v): got %d level0 buckets; want %d",
+				tt.ratio, table.level0Len, tt.level0Len)
+		}
+		data, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Table
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		for i, key := range keys {
+			if n, ok := decoded.Lookup(key); !ok || n != uint32(i) {
+				t.Fatalf("WithLevel0Ratio(%v): Lookup(%s): got (%d, %t); want (%d, true)",
+					tt.ratio, key, n, ok, i)
+			}
+		}
+	}
+	for _, ratio := range []float64{0, -1, math.NaN()} {
+		if _, err := NewBuilder(WithLevel0Ratio(ratio)).Build(keys); !errors.Is(err, ErrInvalidLevel0Ratio) {
+			t.Errorf("WithLevel0Ratio(%v): got err=%v; want one wrapping %v",
+				ratio, err, ErrInvalidLevel0Ratio)
+		}
+	}
+}
+
+// A cancelingHasher cancels a context once it has computed a given number of
+// hashes.
+type cancelingHasher struct {
+	fnvHasher
+	calls  *int
+	cancel context.CancelFunc
+}
+
+func (h cancelingHasher) Hash(seed uint32, data []byte) uint32 {
+	if *h.calls--; *h.calls == 0 {
+		h.cancel()
+	}
+	return h.fnvHasher.Hash(seed, data)
+}
+
+func (cancelingHasher) ID() byte { return 206 }
+
+func TestBuildContext(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 2 * len(keys) // past bucketing, into the seed search
+	b := NewBuilder(WithHasher(cancelingHasher{calls: &calls, cancel: cancel}))
+	if _, err := b.BuildContext(ctx, keys); err != context.Canceled {
+		t.Errorf("BuildContext canceled mid-build: got err=%v; want %v", err, context.Canceled)
+	}
+	if calls > 0 {
+		t.Errorf("BuildContext returned before the context was canceled")
+	}
+	if _, err := BuildContext(ctx, keys, 1.0, 0.01); err != context.Canceled {
+		t.Errorf("BuildContext with a canceled context: got err=%v; want %v", err, context.Canceled)
+	}
+
+	// A table which can never be built gives up at the deadline rather
+	// than after exhausting the seeds at every load factor.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := NewBuilder(WithHasher(collidingHasher{})).BuildContext(ctx, keys)
+	if err != context.DeadlineExceeded {
+		t.Errorf("BuildContext with a deadline: got err=%v; want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("BuildContext with a deadline took %v", elapsed)
+	}
+
+	table, err := BuildContext(context.Background(), keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !table.Equal(want) {
+		t.Error("BuildContext gave a different table from Build")
+	}
+}
+
+func TestBuilder_progress(t *testing.T) {
+	keys := make([]string, 20000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	var dones, totals []int
+	b := NewBuilder(WithProgress(func(done, total int) {
+		dones = append(dones, done)
+		totals = append(totals, total)
+	}))
+	table, err := b.Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dones) < 2 {
+		t.Fatalf("got %d progress updates; want several", len(dones))
+	}
+	total := totals[len(totals)-1]
+	for i, done := range dones {
+		if totals[i] != total {
+			t.Errorf("update %d: got total %d; want %d", i, totals[i], total)
+		}
+		if done <= 0 || done > total || i > 0 && done <= dones[i-1] {
+			t.Errorf("update %d: got done %d after %v", i, done, dones[:i])
+		}
+	}
+	if got := dones[len(dones)-1]; got != total {
+		t.Errorf("last update: got done %d; want %d", got, total)
+	}
+	// The total counts the distinct buckets of the keys.
+	var nonEmpty int
+	seen := make(map[uint32]bool)
+	for _, key := range keys {
+		i0 := uint32(table.reduction.index(hashString(nil, 0, key), table.level0Len))
+		if !seen[i0] {
+			seen[i0] = true
+			nonEmpty++
+		}
+	}
+	if total != nonEmpty {
+		t.Errorf("got total %d; want %d non-empty buckets", total, nonEmpty)
+	}
+}
+
+func TestBuilder_sortedUniqueKeys(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%08d", i)
+	}
+	table, err := NewBuilder(WithSortedUniqueKeys()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := NewBuilder().Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !table.Equal(want) {
+		t.Error("got a different table than without WithSortedUniqueKeys")
+	}
+	for i, key := range keys {
+		if n, ok := table.Lookup(key); !ok || n != uint32(i) {
+			t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+
+	if err := checkSorted(keys); err != nil {
+		t.Errorf("checkSorted of sorted keys: %v", err)
+	}
+	var dup *DuplicateKeyError
+	if err := checkSorted([]string{"a", "b", "b"}); !errors.As(err, &dup) || dup.Index != 2 {
+		t.Errorf("checkSorted of a repeated key: got %v; want a DuplicateKeyError at index 2", err)
+	}
+	if err := checkSorted([]string{"a", "c", "b"}); err == nil || errors.As(err, &dup) {
+		t.Errorf("checkSorted of unsorted keys: got %v; want an ordering error", err)
+	}
+}
+
+func BenchmarkBuild_sortedUniqueKeys(b *testing.B) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%08d", i)
+	}
+	for _, sorted := range []bool{false, true} {
+		builder := NewBuilder()
+		if sorted {
+			builder = NewBuilder(WithSortedUniqueKeys())
+		}
+		b.Run(fmt.Sprintf("sorted=%t", sorted), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := builder.Build(keys); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestBuilder_bucketSeed(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := NewBuilder().Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeded, err := NewBuilder(WithBucketSeed(12345)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seeded.Equal(table) || slices.Equal(seeded.level1, table.level1) {
+		t.Error("WithBucketSeed(12345): got the same table as with seed 0")
+	}
+	data, err := seeded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := seeded.MarshalCompact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded, decodedCompact, read Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := decodedCompact.UnmarshalBinary(compact); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range []*Table{table, seeded, &decoded, &decodedCompact, &read} {
+		if err := tbl.Verify(); err != nil {
+			t.Error(err)
+		}
+		for i, key := range keys {
+			if n, ok := tbl.Lookup(key); !ok || n != uint32(i) {
+				t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+	}
+	if !decoded.Equal(seeded) || !decodedCompact.Equal(seeded) || !read.Equal(seeded) {
+		t.Error("decoded table differs from the encoded one")
+	}
+
+	table64, err := NewBuilder(WithBucketSeed(12345)).Build64(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = table64.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	var decoded64 Table64
+	if err := decoded64.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		if n, ok := decoded64.Lookup(key); !ok || n != uint64(i) {
+			t.Fatalf("Table64.Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+
+	// Version 7 tables, which predate the bucket seed, used seed 0.
+	allowLegacyFormat(t)
+	if err := decoded.UnmarshalBinary(marshalV7(t, table)); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(table) {
+		t.Error("UnmarshalBinary(v7): got a different table")
+	}
+}
+
+func TestBuilder_memoryLimit(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	// At this load factor the level arrays would take gigabytes, so the
+	// build can only succeed in failing if it checks before allocating.
+	b := NewBuilder(WithLoadFactor(1e-6), WithMemoryLimit(1<<20))
+	if _, err := b.Build(keys); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("Build: got %v; want ErrMemoryLimit", err)
+	}
+	if _, err := b.Build64(keys); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("Build64: got %v; want ErrMemoryLimit", err)
+	}
+
+	table, err := NewBuilder(WithMemoryLimit(1 << 20)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		if n, ok := table.Lookup(key); !ok || n != uint32(i) {
+			t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+	// The level arrays of 1000 keys take 5000 bytes, and 1000 of scratch.
+	if _, err := NewBuilder(WithMemoryLimit(5000)).Build(keys); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("Build with a limit below the level arrays: got %v; want ErrMemoryLimit", err)
+	}
+}
+
+func TestBuilder_maxKeyLen(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	keys[700] = strings.Repeat("x", 1<<20)
+	b := NewBuilder(WithMaxKeyLen(1024))
+	_, err := b.Build(keys)
+	var lenErr *KeyTooLongError
+	if !errors.As(err, &lenErr) {
+		t.Fatalf("Build: got err=%v; want a *KeyTooLongError", err)
+	}
+	if lenErr.Index != 700 || lenErr.Len != 1<<20 || lenErr.Max != 1024 {
+		t.Errorf("Build: got %+v; want index 700, length %d, and limit 1024", *lenErr, 1<<20)
+	}
+	if _, err := b.Build64(keys); !errors.As(err, &lenErr) || lenErr.Index != 700 {
+		t.Errorf("Build64: got err=%v; want a *KeyTooLongError for index 700", err)
+	}
+
+	// Keys at the limit, and any keys without one, are accepted.
+	keys[700] = strings.Repeat("x", 1024)
+	if _, err := b.Build(keys); err != nil {
+		t.Errorf("Build with a key at the limit: %v", err)
+	}
+	keys[700] = strings.Repeat("x", 1<<20)
+	if _, err := NewBuilder().Build(keys); err != nil {
+		t.Errorf("Build without a limit: %v", err)
+	}
+}
+
+// BenchmarkBuild_loadFactor contrasts minimal tables with sparser ones, which
+// trade memory for fewer seed attempts.
+func BenchmarkBuild_loadFactor(b *testing.B) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, lf := range []float32{1.0, 0.99, 0.9, 0.75, 0.5} {
+		b.Run(fmt.Sprint(lf), func(b *testing.B) {
+			builder := NewBuilder(WithLoadFactor(lf))
+			var table *Table
+			for i := 0; i < b.N; i++ {
+				var err error
+				if table, err = builder.Build(keys); err != nil {
+					b.Fatal(err)
+				}
+			}
+			stats := table.Stats()
+			b.ReportMetric(float64(stats.TotalSeedAttempts)/float64(len(keys)), "seeds/key")
+			b.ReportMetric(float64(stats.MemoryBytes)/float64(len(keys)), "B/key")
+		})
+	}
+}
+
+func TestWithHashBits(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	narrow, err := NewBuilder(WithHashBits(32)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def, err := NewBuilder().Build(keys); err != nil {
+		t.Fatal(err)
+	} else if !narrow.Equal(def) {
+		t.Error("WithHashBits(32): got a different table than the default")
+	}
+	for _, opts := range [][]Option{
+		{WithHashBits(64)},
+		{WithHashBits(64), WithFastRange(), WithNarrowSeeds()},
+		{WithHashBits(64), WithPowerOfTwoSizes(), WithFingerprint(8)},
+	} {
+		table, err := NewBuilder(opts...).Build(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !table.wideHash {
+			t.Fatal("WithHashBits(64): got a table placed by 32-bit hashes")
+		}
+		data, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		compact, err := table.MarshalCompact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded, decodedCompact, read Table
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := decodedCompact.UnmarshalBinary(compact); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := read.ReadFrom(bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+		for _, tbl := range []*Table{table, &decoded, &decodedCompact, &read, table.Clone()} {
+			if !tbl.Equal(table) {
+				t.Error("decoded table differs from the encoded one")
+			}
+			if err := tbl.Verify(); err != nil {
+				t.Error(err)
+			}
+			ns, oks := tbl.LookupAll(keys)
+			for i, key := range keys {
+				if n, ok := tbl.Lookup(key); !ok || n != uint32(i) || ns[i] != n || !oks[i] {
+					t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+				}
+			}
+		}
+	}
+
+	// The width is part of the table: the same seeds placed by 32-bit
+	// hashes describe another table.
+	wide, err := NewBuilder(WithHashBits(64)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wide.Equal(narrow) {
+		t.Error("WithHashBits(64) and WithHashBits(32) gave equal tables")
+	}
+	wide.wideHash = false
+	var misplaced int
+	for i, key := range keys {
+		if n, _ := wide.Lookup(key); n != uint32(i) {
+			misplaced++
+		}
+	}
+	if misplaced < len(keys)/2 {
+		t.Errorf("looking up a wide table with 32-bit hashes: got %d of %d keys misplaced; want most",
+			misplaced, len(keys))
+	}
+
+	for _, bits := range []int{-64, 16, 48, 128} {
+		if _, err := NewBuilder(WithHashBits(bits)).Build(keys); !errors.Is(err, ErrInvalidHashBits) {
+			t.Errorf("WithHashBits(%d): got %v; want ErrInvalidHashBits", bits, err)
+		}
+	}
+}
+
+// BenchmarkBuild_hashBits compares placing a few million keys with 32-bit
+// and with 64-bit hashes. At this size, the tables are far from the 2^32
+// slots at which 32-bit hashes start to cluster, so the seed attempts are
+// about the same and the second hash only adds time.
+func BenchmarkBuild_hashBits(b *testing.B) {
+	keys := make([]string, 4<<20)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, bits := range []int{32, 64} {
+		b.Run(strconv.Itoa(bits), func(b *testing.B) {
+			builder := NewBuilder(WithHashBits(bits), WithoutBloom())
+			var table *Table
+			for i := 0; i < b.N; i++ {
+				var err error
+				if table, err = builder.Build(keys); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(table.Stats().TotalSeedAttempts)/float64(len(keys)), "seeds/key")
+		})
+	}
+}
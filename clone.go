@@ -0,0 +1,41 @@
+package mph
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/instabid/bloom"
+)
+
+// Clone returns a deep copy of t which shares no memory with it. In
+// particular, a clone of a table opened with OpenMmap remains usable after
+// the original is closed.
+func (t *Table) Clone() *Table {
+	c := *t
+	c.filter = cloneFilter(t.filter)
+	c.level0 = slices.Clone(t.level0)
+	c.level0u16 = slices.Clone(t.level0u16)
+	c.level1 = slices.Clone(t.level1)
+	c.keyData = strings.Clone(t.keyData)
+	c.keyEnds = slices.Clone(t.keyEnds)
+	c.keyPrefixes = slices.Clone(t.keyPrefixes)
+	c.fingerprints = slices.Clone(t.fingerprints)
+	c.mapping = nil
+	c.aliased = false
+	return &c
+}
+
+// cloneFilter returns a deep copy of f, which may be nil.
+func cloneFilter(f *bloom.Filter) *bloom.Filter {
+	if f == nil {
+		return nil
+	}
+	data, err := f.MarshalBinary()
+	if err == nil {
+		c := new(bloom.Filter)
+		if err = c.UnmarshalBinary(data); err == nil {
+			return c
+		}
+	}
+	panic("mph: cannot copy bloom filter: " + err.Error())
+}
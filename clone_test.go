@@ -0,0 +1,69 @@
+package mph
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := table.Clone()
+	clone := table.Clone()
+	if !clone.Equal(table) {
+		t.Fatal("Clone: got a table which is not equal to the original")
+	}
+	if clone.filter == table.filter {
+		t.Error("Clone: the clone shares the original's bloom filter")
+	}
+	for i := range clone.level0 {
+		clone.level0[i]++
+	}
+	for i := range clone.level1 {
+		clone.level1[i]++
+	}
+	for i := range clone.keyEnds {
+		clone.keyEnds[i]++
+	}
+	clone.filter.Add("absent")
+	if !table.Equal(want) {
+		t.Error("modifying the clone changed the original")
+	}
+	if table.Contains("absent") && !want.Contains("absent") {
+		t.Error("adding to the clone's bloom filter changed the original's")
+	}
+
+	// A clone of a mapped table doesn't refer to the mapping.
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "table")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mapped, err := OpenMmap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone = mapped.Clone()
+	if err := mapped.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !clone.Equal(table) {
+		t.Error("Clone of a mapped table: got a table which is not equal to the original")
+	}
+	for i, key := range keys {
+		if n, ok := clone.LookupExact(key); !ok || n != uint32(i) {
+			t.Errorf("LookupExact(%s) after closing the original: got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package mph
+
+import "sort"
+
+// A CollisionReport lists the keys of a table which share an index, as found
+// by a Builder configured WithCollisionReport.
+type CollisionReport struct {
+	// Collisions holds one entry for each shared index, in increasing
+	// order of index.
+	Collisions []Collision
+}
+
+// A Collision is an index which several keys share.
+type Collision struct {
+	Index uint32
+	Keys  []string // in increasing order
+}
+
+// fill sets r to the collisions among keys, which are sorted, given the
+// index of each key in m.
+func (r *CollisionReport) fill(keys []string, m map[string]uint32) {
+	byIndex := make(map[uint32][]string)
+	for _, key := range keys {
+		n := m[key]
+		byIndex[n] = append(byIndex[n], key)
+	}
+	*r = CollisionReport{}
+	for n, keys := range byIndex {
+		if len(keys) > 1 {
+			r.Collisions = append(r.Collisions, Collision{Index: n, Keys: keys})
+		}
+	}
+	sort.Slice(r.Collisions, func(i, j int) bool {
+		return r.Collisions[i].Index < r.Collisions[j].Index
+	})
+}
@@ -0,0 +1,42 @@
+package mph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithCollisionReport(t *testing.T) {
+	m := map[string]uint32{"foo": 7, "bar": 3, "baz": 7, "quux": 1, "a": 3, "b": 3}
+	var report CollisionReport
+	b := NewBuilder(WithCollisionReport(&report))
+	table, err := b.BuildWithIndices(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Collision{
+		{Index: 3, Keys: []string{"a", "b", "bar"}},
+		{Index: 7, Keys: []string{"baz", "foo"}},
+	}
+	if !reflect.DeepEqual(report.Collisions, want) {
+		t.Errorf("got collisions %v; want %v", report.Collisions, want)
+	}
+	for key, want := range m {
+		if n, ok := table.Lookup(key); !ok || n != want {
+			t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, want)
+		}
+	}
+
+	if _, err := b.BuildWithIndices(map[string]uint32{"foo": 1, "bar": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if report.Collisions != nil {
+		t.Errorf("got collisions %v for distinct indices; want none", report.Collisions)
+	}
+	report.Collisions = want
+	if _, err := b.Build([]string{"foo", "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if report.Collisions != nil {
+		t.Errorf("got collisions %v after Build; want none", report.Collisions)
+	}
+}
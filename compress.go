@@ -0,0 +1,136 @@
+package mph
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// A Compression selects how Marshal compresses the encoding of a table.
+type Compression byte
+
+const (
+	// NoCompression leaves the encoding of MarshalBinary as it is.
+	NoCompression Compression = iota
+	// Snappy compresses the encoding in snappy's block format, as does
+	// MarshalSnappy. It is fast, but compresses less than Zstd.
+	Snappy
+	// Zstd compresses the encoding with zstd, which suits large tables
+	// which are stored rather than decoded often.
+	Zstd
+)
+
+// compressedMagic starts the encodings of compressed tables, followed by
+// their Compression. Its first byte is not a format version, so compressed
+// encodings cannot be mistaken for those of MarshalBinary.
+const compressedMagic = "\x89MPH"
+
+// A MarshalOption configures Marshal.
+type MarshalOption func(*marshalConfig)
+
+type marshalConfig struct {
+	compression Compression
+}
+
+// WithCompression sets the compression which Marshal applies. The default is
+// NoCompression.
+func WithCompression(c Compression) MarshalOption {
+	return func(m *marshalConfig) { m.compression = c }
+}
+
+// zstdEncoder and zstdDecoder are shared, since creating them is expensive
+// and their EncodeAll and DecodeAll may be called concurrently.
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) { return zstd.NewWriter(nil) })
+	zstdDecoder = sync.OnceValues(newZstdDecoder)
+)
+
+// newZstdDecoder returns a decoder which refuses frames claiming to
+// decompress to more than maxDecodedLen bytes. zstd gives each frame a window
+// of at least zstd.MinWindowSize and refuses windows beyond its memory limit,
+// so neither limit is below that; Unmarshal checks the exact length itself.
+func newZstdDecoder() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil,
+		zstd.WithDecoderMaxMemory(max(zstd.MinWindowSize, maxDecodedLen)),
+		zstd.WithDecoderMaxWindow(max(zstd.MinWindowSize, min(maxDecodedLen, zstd.MaxWindowSize))))
+}
+
+// Marshal returns the encoding of t, compressed as selected by opts. Without
+// compression it is that of MarshalBinary, which remains the canonical
+// encoding; compressed encodings start with a marker recording the
+// compression, so that Unmarshal can decode any of them.
+func Marshal(t *Table, opts ...MarshalOption) ([]byte, error) {
+	var m marshalConfig
+	for _, opt := range opts {
+		opt(&m)
+	}
+	data, err := t.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if m.compression == NoCompression {
+		return data, nil
+	}
+	out := append([]byte(compressedMagic), byte(m.compression))
+	switch m.compression {
+	case Snappy:
+		return append(out, snappy.Encode(nil, data)...), nil
+	case Zstd:
+		enc, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return enc.EncodeAll(data, out), nil
+	}
+	return nil, fmt.Errorf("mph.Marshal: unknown compression %d", m.compression)
+}
+
+// Unmarshal decodes a table encoded by Marshal, with any compression, or by
+// MarshalBinary or MarshalCompact.
+func Unmarshal(data []byte) (*Table, error) {
+	t := new(Table)
+	rest, ok := bytes.CutPrefix(data, []byte(compressedMagic))
+	if !ok {
+		if err := t.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	if len(rest) == 0 {
+		return nil, fmt.Errorf("%w for the compression", ErrShortData)
+	}
+	var raw []byte
+	switch c := Compression(rest[0]); c {
+	case Snappy:
+		var err error
+		if raw, err = decodeSnappy(rest[1:]); err != nil {
+			return nil, fmt.Errorf("mph.Unmarshal: %w", err)
+		}
+	case Zstd:
+		dec, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		raw, err = dec.DecodeAll(rest[1:], nil)
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+			return nil, fmt.Errorf("%w: %w", ErrDecodedTooLarge, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("mph.Unmarshal: %w", err)
+		}
+		if uint64(len(raw)) > maxDecodedLen {
+			return nil, fmt.Errorf("%w: decompresses to %d bytes, more than the limit of %d", ErrDecodedTooLarge, len(raw), maxDecodedLen)
+		}
+	default:
+		return nil, fmt.Errorf("mph.Unmarshal: unknown compression %d", c)
+	}
+	// raw is ours, so the table may refer to it rather than copy it.
+	if err := t.UnmarshalBinaryNoCopy(raw); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,119 @@
+package mph
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestMarshal(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("https://example.com/products/%d/reviews", i)
+	}
+	table, err := BuildWithKeys(keys, 0.9, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := table.MarshalCompact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodings := map[string][]byte{"MarshalBinary": raw, "MarshalCompact": compact}
+	for _, c := range []Compression{NoCompression, Snappy, Zstd} {
+		data, err := Marshal(table, WithCompression(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c == NoCompression && !bytes.Equal(data, raw) {
+			t.Error("Marshal without compression differs from MarshalBinary")
+		}
+		if c != NoCompression && len(data) >= len(raw)/2 {
+			t.Errorf("Marshal with compression %d: got %d bytes; want less than half of %d", c, len(data), len(raw))
+		}
+		encodings[fmt.Sprintf("Marshal with compression %d", c)] = data
+	}
+	for name, data := range encodings {
+		decoded, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Unmarshal of %s: %v", name, err)
+		}
+		if !decoded.Equal(table) {
+			t.Errorf("Unmarshal of %s: got a different table", name)
+		}
+		for i, key := range keys {
+			if n, ok := decoded.LookupExact(key); !ok || n != uint32(i) {
+				t.Fatalf("Unmarshal of %s: LookupExact(%s): got (%d, %t); want (%d, true)", name, key, n, ok, i)
+			}
+		}
+	}
+
+	if _, err := Marshal(table, WithCompression(7)); err == nil {
+		t.Error("Marshal with an unknown compression: got nil error")
+	}
+	for name, data := range map[string][]byte{
+		"an unknown compression": []byte(compressedMagic + "\x07"),
+		"a missing compression":  []byte(compressedMagic),
+		"corrupt snappy data":    []byte(compressedMagic + "\x01\xff"),
+		"corrupt zstd data":      []byte(compressedMagic + "\x02\xff"),
+	} {
+		if _, err := Unmarshal(data); err == nil {
+			t.Errorf("Unmarshal of %s: got nil error", name)
+		}
+	}
+}
+
+func TestUnmarshal_oversized(t *testing.T) {
+	// The encoding of the small table is below zstd.MinWindowSize, which
+	// zstd cannot enforce, and that of the large one above it.
+	small, err := Build([]string{"a", "b", "c"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	large, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The shared zstd decoder takes its limit when created, so each limit
+	// needs a new one.
+	defer func(limit uint64, dec func() (*zstd.Decoder, error)) {
+		maxDecodedLen, zstdDecoder = limit, dec
+	}(maxDecodedLen, zstdDecoder)
+	for name, table := range map[string]*Table{"small": small, "large": large} {
+		raw, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range []Compression{Snappy, Zstd} {
+			data, err := Marshal(table, WithCompression(c))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, limit := range []uint64{uint64(len(raw)), uint64(len(raw)) - 1} {
+				maxDecodedLen = limit
+				zstdDecoder = sync.OnceValues(newZstdDecoder)
+				_, err := Unmarshal(data)
+				if limit == uint64(len(raw)) && err != nil {
+					t.Errorf("Unmarshal of the %s table with compression %d at the limit: %v", name, c, err)
+				}
+				if limit < uint64(len(raw)) && !errors.Is(err, ErrDecodedTooLarge) {
+					t.Errorf("Unmarshal of the %s table with compression %d, %d bytes above the limit of %d: got err=%v; want ErrDecodedTooLarge",
+						name, c, len(raw), limit, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build mphdebug
+
+package mph
+
+// debugChecks enables checks of promises made by the caller, such as that of
+// WithSortedUniqueKeys, which are too costly to make by default.
+const debugChecks = true
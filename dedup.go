@@ -0,0 +1,67 @@
+package mph
+
+// DedupKeys returns keys without their repeats, each key keeping its first
+// occurrence in order, so that a table built from the result gives each key
+// the number of distinct keys which precede its first occurrence as its
+// index, as BuildFromReader does. Rather than a map of every key, it groups
+// the keys by hash, as building does to detect duplicates, and compares only
+// keys of the same group, so besides the result it needs a few words per key.
+// If keys has no repeats, DedupKeys returns it itself.
+func DedupKeys(keys []string) []string {
+	repeat := repeats(keys)
+	if repeat == nil {
+		return keys
+	}
+	distinct := make([]string, 0, len(keys))
+	for i, key := range keys {
+		if !repeat[i] {
+			distinct = append(distinct, key)
+		}
+	}
+	return distinct
+}
+
+// repeats reports, for each of keys, whether it equals an earlier one, or
+// returns nil if none does.
+func repeats(keys []string) []bool {
+	s := getScratch()
+	defer putScratch(s)
+	var repeat []bool
+	for _, bucket := range new(Builder).bucketize(keys, max(len(keys)/4, 1), reduceMod, s) {
+		repeat = markRepeats(keys, bucket.vals, s.seen, repeat)
+	}
+	return repeat
+}
+
+// markRepeats sets repeat[i] for each of the indices vals, which are in
+// increasing order, whose key equals that of an earlier one, allocating
+// repeat for all of keys at the first. Like firstDuplicate, it uses seen as
+// scratch space for large buckets.
+func markRepeats(keys []string, vals []int, seen map[string]struct{}, repeat []bool) []bool {
+	mark := func(i int) {
+		if repeat == nil {
+			repeat = make([]bool, len(keys))
+		}
+		repeat[i] = true
+	}
+	if len(vals) <= 8 {
+		for j, i := range vals {
+			for _, k := range vals[:j] {
+				if keys[k] == keys[i] {
+					mark(i)
+					break
+				}
+			}
+		}
+		return repeat
+	}
+	clear(seen)
+	for _, i := range vals {
+		if _, ok := seen[keys[i]]; ok {
+			mark(i)
+			continue
+		}
+		seen[keys[i]] = struct{}{}
+	}
+	return repeat
+}
@@ -0,0 +1,76 @@
+package mph
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestDedupKeys(t *testing.T) {
+	for _, tt := range []struct {
+		keys, want []string
+	}{
+		{nil, nil},
+		{[]string{"a"}, []string{"a"}},
+		{[]string{"a", "a"}, []string{"a"}},
+		{[]string{"a", "a", "b", "c"}, []string{"a", "b", "c"}},
+		{[]string{"a", "b", "c", "c"}, []string{"a", "b", "c"}},
+		{[]string{"c", "b", "a", "b", "c"}, []string{"c", "b", "a"}},
+		{[]string{"", "x", "", "x", ""}, []string{"", "x"}},
+		{[]string{"z", "z", "z", "z", "z", "z", "z", "z", "z", "z", "y", "z"}, []string{"z", "y"}},
+	} {
+		if got := DedupKeys(tt.keys); !slices.Equal(got, tt.want) {
+			t.Errorf("DedupKeys(%q): got %q; want %q", tt.keys, got, tt.want)
+		}
+	}
+
+	// Each key keeps the position of its first occurrence among many.
+	var keys, want []string
+	for i := 0; i < 3000; i++ {
+		keys = append(keys, strconv.Itoa(i*7919%1000))
+		if i < 1000 {
+			want = append(want, keys[i])
+		}
+	}
+	if got := DedupKeys(keys); !slices.Equal(got, want) {
+		t.Errorf("DedupKeys of 1000 keys repeated thrice: got %d keys, starting %q; want %d, starting %q",
+			len(got), got[:5], len(want), want[:5])
+	}
+	if got := DedupKeys(want); &got[0] != &want[0] {
+		t.Error("DedupKeys of distinct keys: got a copy")
+	}
+}
+
+func TestWithDedup(t *testing.T) {
+	keys := []string{"b", "a", "b", "C", "a", "c", "d", "B"}
+	if _, err := NewBuilder().Build(keys); !errors.As(err, new(*DuplicateKeyError)) {
+		t.Fatalf("Build without WithDedup: got err=%v; want *DuplicateKeyError", err)
+	}
+	for _, tt := range []struct {
+		opts []Option
+		want []string
+	}{
+		{[]Option{WithDedup()}, []string{"b", "a", "C", "c", "d", "B"}},
+		{[]Option{WithDedup(), WithCaseFold()}, []string{"b", "a", "C", "d"}},
+	} {
+		table, err := NewBuilder(tt.opts...).Build(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table.Len() != len(tt.want) {
+			t.Errorf("Len: got %d; want %d", table.Len(), len(tt.want))
+		}
+		for i, key := range tt.want {
+			if n, ok := table.Lookup(key); !ok || n != uint32(i) {
+				t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+	}
+
+	// Keys which the folding makes equal still conflict in BuildWithIndices.
+	m := map[string]uint32{"a": 1, "A": 2}
+	if _, err := NewBuilder(WithDedup(), WithCaseFold()).BuildWithIndices(m); !errors.As(err, new(*DuplicateKeyError)) {
+		t.Errorf("BuildWithIndices WithDedup: got err=%v; want *DuplicateKeyError", err)
+	}
+}
@@ -0,0 +1,60 @@
+package mph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// String returns a one-line summary of t for debugging: its numbers of keys,
+// level0 buckets, and level1 slots, its load factor, and the false positive
+// rate of its bloom filter or the width of its fingerprints. Unlike Dump, its
+// cost does not depend on the size of t.
+func (t *Table) String() string {
+	bloom := "none"
+	switch {
+	case t.filter != nil && t.fpProb == 0:
+		bloom = "unknown rate"
+	case t.filter != nil:
+		bloom = "rate " + strconv.FormatFloat(t.fpProb, 'g', -1, 64)
+	}
+	if t.fingerprints != nil {
+		fingerprints := strconv.Itoa(t.fingerprintBits) + "-bit fingerprints"
+		if t.filter == nil {
+			bloom = fingerprints
+		} else {
+			bloom += " and " + fingerprints
+		}
+	}
+	return fmt.Sprintf("mph.Table{keys: %d, level0: %d, level1: %d, load factor: %g, bloom: %s}",
+		t.numKeys, t.level0Len, t.level1Len, t.loadFactor, bloom)
+}
+
+// Dump writes the summary returned by String to w, followed by the contents
+// of t's level arrays, one entry per line: the seed of each level0 bucket, or
+// its probe count if it was placed by the fallback of WithProbingFallback,
+// and the index in each level1 slot, along with the key stored for it by
+// BuildWithKeys. It is meant for debugging small tables, since the output is
+// proportional to the size of t.
+func (t *Table) Dump(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, t.String())
+	fmt.Fprintln(bw, "level0:")
+	for i := 0; i < t.level0Len; i++ {
+		if seed := t.seed(i); t.fallback && seed&fallbackBit != 0 {
+			fmt.Fprintf(bw, "\t%d: probe %d\n", i, seed&^fallbackBit)
+		} else {
+			fmt.Fprintf(bw, "\t%d: seed %d\n", i, seed)
+		}
+	}
+	fmt.Fprintln(bw, "level1:")
+	for i, n := range t.level1 {
+		if key, ok := t.Key(n); ok {
+			fmt.Fprintf(bw, "\t%d: %d %q\n", i, n, key)
+		} else {
+			fmt.Fprintf(bw, "\t%d: %d\n", i, n)
+		}
+	}
+	return bw.Flush()
+}
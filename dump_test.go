@@ -0,0 +1,75 @@
+package mph
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTable_String(t *testing.T) {
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = fmt.Sprint("key", i)
+	}
+	table, err := Build(keys, 0.5, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := table.String()
+	for _, want := range []string{
+		"keys: 100",
+		fmt.Sprintf("level0: %d", table.level0Len),
+		fmt.Sprintf("level1: %d", table.level1Len),
+		"load factor: 0.5",
+		"bloom: rate 0.01",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("String: got %q; want it to contain %q", s, want)
+		}
+	}
+	if strings.Contains(s, "\n") {
+		t.Errorf("String: got %q; want a single line", s)
+	}
+
+	noBloom, err := NewBuilder(WithoutBloom()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := noBloom.String(); !strings.Contains(s, "bloom: none") {
+		t.Errorf("String WithoutBloom: got %q; want it to contain %q", s, "bloom: none")
+	}
+	slotFingerprint, err := NewBuilder(WithSlotFingerprint(8)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, want := slotFingerprint.String(), "bloom: rate 0.01 and 8-bit fingerprints"; !strings.Contains(s, want) {
+		t.Errorf("String WithSlotFingerprint: got %q; want it to contain %q", s, want)
+	}
+}
+
+func TestTable_Dump(t *testing.T) {
+	keys := []string{"foo", "bar", "baz"}
+	table, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := table.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if want := 3 + table.level0Len + table.level1Len; len(lines) != want {
+		t.Fatalf("Dump: got %d lines; want %d:\n%s", len(lines), want, buf.String())
+	}
+	if lines[0] != table.String() {
+		t.Errorf("Dump: got first line %q; want %q", lines[0], table.String())
+	}
+	for i, key := range keys {
+		n, _ := table.Lookup(key)
+		want := fmt.Sprintf(": %d %q", n, key)
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Dump: key %d: got\n%s\nwant a line ending in %q", i, buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package mph
+
+import (
+	"bytes"
+	"slices"
+)
+
+// Equal reports whether t and other are the same table: whether they use the
+// same hash function, level arrays, bloom filter, and stored keys, and so
+// give the same result for every lookup. Unlike comparing their encodings, it
+// ignores how the tables came about, such as the load factor requested.
+func (t *Table) Equal(other *Table) bool {
+	if t == other {
+		return true
+	}
+	if t == nil || other == nil {
+		return false
+	}
+	if t.level0Len != other.level0Len || t.level1Len != other.level1Len ||
+		t.numKeys != other.numKeys || !sameHasher(t.hasher, other.hasher) ||
+		t.reduction != other.reduction || t.fallback != other.fallback ||
+		t.wideHash != other.wideHash || t.ids != other.ids || t.folding != other.folding ||
+		t.bucketSeed != other.bucketSeed ||
+		!slices.Equal(t.level0, other.level0) || !slices.Equal(t.level1, other.level1) ||
+		(t.level0u16 == nil) != (other.level0u16 == nil) ||
+		!slices.Equal(t.level0u16, other.level0u16) ||
+		(t.keyEnds == nil) != (other.keyEnds == nil) ||
+		!slices.Equal(t.keyEnds, other.keyEnds) || t.keyData != other.keyData ||
+		(t.keyPrefixes == nil) != (other.keyPrefixes == nil) ||
+		!slices.Equal(t.keyPrefixes, other.keyPrefixes) ||
+		t.fingerprintBits != other.fingerprintBits || !bytes.Equal(t.fingerprints, other.fingerprints) {
+		return false
+	}
+	if (t.filter == nil) != (other.filter == nil) {
+		return false
+	}
+	if t.filter == nil || t.filter == other.filter {
+		return true
+	}
+	a, err := t.filter.MarshalBinary()
+	if err != nil {
+		return false
+	}
+	b, err := other.filter.MarshalBinary()
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(a, b)
+}
@@ -0,0 +1,69 @@
+package mph
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	build := func(keys []string) *Table {
+		t.Helper()
+		table, err := Build(keys, 1.0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return table
+	}
+	table := build(keys)
+	if !table.Equal(table) {
+		t.Error("Equal: table is not equal to itself")
+	}
+	if other := build(keys); !table.Equal(other) || !other.Equal(table) {
+		t.Error("Equal: tables built from the same keys are not equal")
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !table.Equal(&decoded) {
+		t.Error("Equal: table is not equal to its decoded encoding")
+	}
+
+	extra := build(append(keys[:len(keys):len(keys)], "extra"))
+	withKeys, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withHasher, err := NewBuilder(WithHasher(fnvHasher{})).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutBloom, err := NewBuilder(WithoutBloom()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lowFP, err := Build(keys, 1.0, 0.0001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, other := range map[string]*Table{
+		"an extra key":         extra,
+		"stored keys":          withKeys,
+		"another hasher":       withHasher,
+		"no bloom filter":      withoutBloom,
+		"another bloom filter": lowFP,
+		"nil":                  nil,
+	} {
+		if table.Equal(other) {
+			t.Errorf("Equal: got true for a table with %s", name)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package mph
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrBuildFailed is matched, by errors.Is, by the *BuildError returned
+	// when no table could be built from the keys at any load factor. Since
+	// the load factor is only lowered when the seed search fails, so is
+	// ErrSeedExhausted.
+	ErrBuildFailed = errors.New("mph: failed creating table")
+
+	// ErrSeedExhausted indicates that no seed placing the keys of some
+	// bucket in free slots was found within the allowed number of attempts.
+	ErrSeedExhausted = errors.New("mph: seed search exhausted")
+
+	// ErrInvalidLoadFactor is returned, wrapped with the offending value,
+	// when building with a load factor outside (0, 1], or NaN.
+	ErrInvalidLoadFactor = errors.New("mph: invalid load factor")
+
+	// ErrInvalidFalsePositiveRate is returned, wrapped with the offending
+	// value, when building with a false positive rate outside (0, 1).
+	ErrInvalidFalsePositiveRate = errors.New("mph: invalid false positive rate")
+
+	// ErrInvalidLevel0Ratio is returned, wrapped with the offending value,
+	// when building with a level0 ratio which is not positive.
+	ErrInvalidLevel0Ratio = errors.New("mph: invalid level0 ratio")
+
+	// ErrInvalidFingerprintBits is returned, wrapped with the offending
+	// value, when building WithFingerprint or WithSlotFingerprint with a
+	// width other than 8 or 16.
+	ErrInvalidFingerprintBits = errors.New("mph: invalid fingerprint width")
+
+	// ErrInvalidHashBits is returned, wrapped with the offending value, when
+	// building WithHashBits with a width other than 32 or 64.
+	ErrInvalidHashBits = errors.New("mph: invalid hash width")
+
+	// ErrFilterMismatch is returned when building with a filter, supplied
+	// using WithFilter, which doesn't contain every key.
+	ErrFilterMismatch = errors.New("mph: bloom filter does not contain the keys")
+
+	// ErrTooManyKeys is returned, wrapped with the number of keys, when
+	// building a Table from more keys than its 32-bit indices can number.
+	// A Table64 can hold them.
+	ErrTooManyKeys = errors.New("mph: too many keys for a Table")
+
+	// ErrNoKeys is returned when merging, or adding keys to, a table which
+	// was built without storing its keys. Merge wraps it with the table's
+	// position.
+	ErrNoKeys = errors.New("mph: table has no stored keys")
+
+	// ErrMemoryLimit is returned, wrapped with the sizes involved, when the
+	// level arrays of a table would exceed the limit set by WithMemoryLimit.
+	ErrMemoryLimit = errors.New("mph: memory limit exceeded")
+
+	// ErrHasherID is returned, wrapped with the ID, when building WithHasher
+	// with a Hasher whose ID is reserved for this package's Hashers or
+	// belongs to a different registered one, under which the table would be
+	// decoded.
+	ErrHasherID = errors.New("mph: hasher ID is taken")
+
+	// ErrHashKeyRequired is returned, wrapped with the ID of the key, when
+	// decoding a table built WithHashKey whose key is not registered with
+	// RegisterHashKey.
+	ErrHashKeyRequired = errors.New("mph: table needs its hash key")
+
+	// ErrInvalidTable is returned, wrapped with a description of the
+	// problem, by Verify for a table which is not internally consistent.
+	ErrInvalidTable = errors.New("mph: invalid table")
+
+	// ErrNotTable is returned, wrapped with a description of the problem,
+	// when decoding data which doesn't start with the magic of the binary
+	// encoding, or which is in a version before it unless AllowLegacyFormat
+	// is set.
+	ErrNotTable = errors.New("mph: not an mph table")
+
+	// ErrDecodedTooLarge is returned, wrapped with the sizes involved or the
+	// decompressor's own error, by Unmarshal and UnmarshalSnappy for data
+	// which decompresses to more than an encoding of a table could need.
+	ErrDecodedTooLarge = errors.New("mph: decompressed data too large")
+
+	// ErrShortData is returned, wrapped with a description of the missing
+	// part, when decoding data which ends before the encoded table does.
+	ErrShortData = errors.New("mph: data too short")
+)
+
+// A DuplicateKeyError is returned when building a table from keys which
+// contain the same key more than once.
+type DuplicateKeyError struct {
+	Key   string
+	Index int // the index of the second occurrence of Key
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("mph: duplicate key %q at index %d", e.Key, e.Index)
+}
+
+// A KeyTooLongError is returned when building, with a limit set by
+// WithMaxKeyLen, from keys one of which exceeds it.
+type KeyTooLongError struct {
+	Index int // the index of the first key which is too long
+	Len   int // its length in bytes
+	Max   int // the limit
+}
+
+func (e *KeyTooLongError) Error() string {
+	return fmt.Sprintf("mph: key at index %d is %d bytes long, more than the limit of %d", e.Index, e.Len, e.Max)
+}
+
+// A ChunkError is returned by ReadFrom and UnmarshalBinary when a chunk of an
+// encoding written by WriteChunked is corrupt or truncated. Chunk names it:
+// "header", "bloom", "level0", "level1", "fingerprints", or "keys".
+type ChunkError struct {
+	Chunk string
+	Err   error // what is wrong with the chunk
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("mph: %s chunk: %v", e.Chunk, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// A BuildError is returned when no table could be built from the keys
+// because the seed search was exhausted at every load factor down to 0.1. It
+// describes the last attempt, which helps tell inputs which merely need a
+// lower load factor or more seed attempts from pathological ones, such as
+// keys whose hashes collide. It matches both ErrBuildFailed and
+// ErrSeedExhausted.
+type BuildError struct {
+	// LoadFactor is the last load factor tried.
+	LoadFactor float32
+	// MaxBucketSize is the number of keys in the largest level0 bucket.
+	MaxBucketSize int
+	// PlacedBuckets is the number of buckets, of Buckets, which were placed,
+	// largest first, before the seed search was exhausted for one of
+	// StuckBucketSize keys.
+	PlacedBuckets   int
+	Buckets         int
+	StuckBucketSize int
+	// TotalSeedAttempts is the number of seeds tried at all load factors.
+	TotalSeedAttempts uint64
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("%v: %v at every load factor down to 0.1; at load factor %v, "+
+		"%d of %d buckets were placed before one of %d keys (the largest has %d)",
+		ErrBuildFailed, ErrSeedExhausted, e.LoadFactor,
+		e.PlacedBuckets, e.Buckets, e.StuckBucketSize, e.MaxBucketSize)
+}
+
+func (e *BuildError) Unwrap() []error {
+	return []error{ErrBuildFailed, ErrSeedExhausted}
+}
@@ -0,0 +1,181 @@
+package mph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+// A collidingHasher hashes every key to the same value, so that no two keys
+// can be placed in distinct slots.
+type collidingHasher struct{}
+
+func (collidingHasher) Hash(seed uint32, data []byte) uint32 { return 0 }
+func (collidingHasher) ID() byte                             { return 202 }
+
+func TestBuild_seedExhausted(t *testing.T) {
+	b := NewBuilder(WithHasher(collidingHasher{}), WithMaxSeedAttempts(10))
+	_, err := b.Build([]string{"foo", "bar"})
+	if !errors.Is(err, ErrBuildFailed) || !errors.Is(err, ErrSeedExhausted) {
+		t.Fatalf("Build: got err=%v; want one wrapping %v and %v",
+			err, ErrBuildFailed, ErrSeedExhausted)
+	}
+	if _, err := b.Build64([]string{"foo", "bar"}); !errors.Is(err, ErrSeedExhausted) {
+		t.Errorf("Build64: got err=%v; want one wrapping %v", err, ErrSeedExhausted)
+	}
+}
+
+// A twinHasher hashes keys like fnvHasher, except that keys starting with
+// "twin" all hash like "twin", so that they can never be told apart.
+type twinHasher struct{ fnvHasher }
+
+func (h twinHasher) Hash(seed uint32, data []byte) uint32 {
+	if bytes.HasPrefix(data, []byte("twin")) {
+		data = data[:4]
+	}
+	return h.fnvHasher.Hash(seed, data)
+}
+func (twinHasher) ID() byte { return 204 }
+
+func TestBuildError(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	keys = append(keys, "twin1", "twin2")
+	b := NewBuilder(WithHasher(twinHasher{}), WithMaxSeedAttempts(100))
+	_, err := b.Build(keys)
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		t.Fatalf("Build: got err=%v; want a *BuildError", err)
+	}
+	if !errors.Is(err, ErrBuildFailed) || !errors.Is(err, ErrSeedExhausted) {
+		t.Errorf("Build: got err=%v; want one wrapping %v and %v", err, ErrBuildFailed, ErrSeedExhausted)
+	}
+	if lf := buildErr.LoadFactor; lf < 0.1 || lf >= 0.1/0.9 {
+		t.Errorf("LoadFactor: got %v; want the last one above 0.1", lf)
+	}
+	if buildErr.Buckets == 0 || buildErr.PlacedBuckets == 0 || buildErr.PlacedBuckets >= buildErr.Buckets {
+		t.Errorf("got %d placed buckets of %d; want some but not all", buildErr.PlacedBuckets, buildErr.Buckets)
+	}
+	if buildErr.StuckBucketSize < 2 || buildErr.StuckBucketSize > buildErr.MaxBucketSize {
+		t.Errorf("got a stuck bucket of %d keys and a largest of %d; want at least 2 keys, at most the largest",
+			buildErr.StuckBucketSize, buildErr.MaxBucketSize)
+	}
+	// Every bucket tried at least one seed at each of the load factors.
+	if buildErr.TotalSeedAttempts < uint64(buildErr.PlacedBuckets)+100 {
+		t.Errorf("TotalSeedAttempts: got %d; want at least %d", buildErr.TotalSeedAttempts, buildErr.PlacedBuckets+100)
+	}
+
+	if _, err := b.Build64(keys); !errors.As(err, &buildErr) || buildErr.StuckBucketSize < 2 {
+		t.Errorf("Build64: got err=%v; want a *BuildError", err)
+	}
+}
+
+func TestUnmarshalBinary_shortData(t *testing.T) {
+	table, err := BuildWithKeys([]string{"foo", "bar", "baz", "quux"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < len(data); n++ {
+		var decoded Table
+		if err := decoded.UnmarshalBinary(data[:n]); !errors.Is(err, ErrShortData) {
+			t.Fatalf("UnmarshalBinary of %d of %d bytes: got err=%v; want one wrapping %v",
+				n, len(data), err, ErrShortData)
+		}
+	}
+	if _, err := UnmarshalMap(data[:4], ValueCodec[int]{}); !errors.Is(err, ErrShortData) {
+		t.Errorf("UnmarshalMap: got err=%v; want one wrapping %v", err, ErrShortData)
+	}
+}
+
+func TestUnmarshalBinary_hugeLengths(t *testing.T) {
+	table, err := BuildWithKeys([]string{"foo", "bar", "baz", "quux"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Lengths far beyond the data must be rejected without first being
+	// allocated, whether or not computing offsets from them would overflow.
+	for word := 0; word < 4; word++ {
+		for _, n := range []uint64{1 << 40, math.MaxInt64, math.MaxUint64} {
+			corrupt := bytes.Clone(data)
+			binary.LittleEndian.PutUint64(corrupt[wordOffset(ver, word):], n)
+			var decoded Table
+			if err := decoded.UnmarshalBinary(corrupt); err == nil {
+				t.Errorf("UnmarshalBinary with header word %d set to %#x: got nil error", word, n)
+			}
+			if _, err := decoded.ReadFrom(bytes.NewReader(corrupt)); err == nil {
+				t.Errorf("ReadFrom with header word %d set to %#x: got nil error", word, n)
+			}
+		}
+	}
+}
+
+func TestCheckKeyCount(t *testing.T) {
+	// Building from 1<<32 keys takes too much memory for a test, so the
+	// check which Build makes is tested directly.
+	for _, n := range []uint64{0, 1, math.MaxUint32} {
+		if err := checkKeyCount(n); err != nil {
+			t.Errorf("checkKeyCount(%d): %v", n, err)
+		}
+	}
+	for _, n := range []uint64{math.MaxUint32 + 1, math.MaxUint64} {
+		if err := checkKeyCount(n); !errors.Is(err, ErrTooManyKeys) {
+			t.Errorf("checkKeyCount(%d): got err=%v; want one wrapping %v", n, err, ErrTooManyKeys)
+		}
+	}
+}
+
+func TestBuild_invalidParams(t *testing.T) {
+	keys := []string{"foo", "bar", "baz"}
+	for _, tt := range []struct {
+		loadFactor float32
+		fpProb     float64
+		want       error
+	}{
+		{-0.5, 0.01, ErrInvalidLoadFactor},
+		{0, 0.01, ErrInvalidLoadFactor},
+		{math.Nextafter32(1, 2), 0.01, ErrInvalidLoadFactor},
+		{1.5, 0.01, ErrInvalidLoadFactor},
+		{float32(math.NaN()), 0.01, ErrInvalidLoadFactor},
+		{1.0, 0, ErrInvalidFalsePositiveRate},
+		{1.0, -0.1, ErrInvalidFalsePositiveRate},
+		{1.0, 1, ErrInvalidFalsePositiveRate},
+		{1.0, 1.5, ErrInvalidFalsePositiveRate},
+		{1.0, math.NaN(), ErrInvalidFalsePositiveRate},
+	} {
+		if _, err := Build(keys, tt.loadFactor, tt.fpProb); !errors.Is(err, tt.want) {
+			t.Errorf("Build(keys, %v, %v): got err=%v; want one wrapping %v",
+				tt.loadFactor, tt.fpProb, err, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		loadFactor float32
+		level1Len  int
+	}{
+		{1, len(keys)},
+		{0.5, 2 * len(keys)},
+		{0.01, 100 * len(keys)},
+	} {
+		table, err := Build(keys, tt.loadFactor, 0.01)
+		if err != nil {
+			t.Errorf("Build(keys, %v, 0.01): %v", tt.loadFactor, err)
+			continue
+		}
+		if got := table.level1Len; got != tt.level1Len {
+			t.Errorf("Build(keys, %v, 0.01): got %d level1 slots; want %d",
+				tt.loadFactor, got, tt.level1Len)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package mph
+
+import "github.com/instabid/bloom"
+
+// A SizeEstimate describes the size of the table which a build would produce.
+type SizeEstimate struct {
+	// Level0Len and Level1Len are the numbers of level0 buckets and level1
+	// slots.
+	Level0Len int
+	Level1Len int
+	// LevelBytes is the size of the level arrays, in memory and encoded.
+	LevelBytes int
+	// BloomBytes is the length of the encoding of the bloom filter, or 0 if
+	// the table would have none.
+	BloomBytes int
+	// FingerprintBytes is the size of the fingerprints of a table built
+	// WithFingerprint, in memory and encoded, or 0.
+	FingerprintBytes int
+	// EncodedBytes is the length of the encoding produced by MarshalBinary.
+	EncodedBytes int
+}
+
+// EstimateSize returns the size of the table which Build would make from
+// keys, without searching for its seeds, for capacity planning.
+func EstimateSize(keys []string, loadFactor float32, fpProb float64) (SizeEstimate, error) {
+	return NewBuilder(WithLoadFactor(loadFactor), WithFalsePositiveRate(fpProb)).EstimateSize(keys)
+}
+
+// EstimateSize returns the size of the Table which b would build from keys.
+// Only the number of keys matters, so they are not checked for duplicates.
+// The estimate assumes that the table is built at the initial load factor,
+// which is nearly always the case at load factors below 1, and that its seeds
+// take 32 bits; a table built WithNarrowSeeds may have level0 entries half
+// that size.
+func (b *Builder) EstimateSize(keys []string) (SizeEstimate, error) {
+	if err := b.validate(); err != nil {
+		return SizeEstimate{}, err
+	}
+	level0Len, level1Len := b.reduction.sizes(len(keys), b.loadFactor, b.level0Ratio)
+	filter := b.filter
+	if filter == nil && !b.noBloom {
+		// An empty filter of the same size encodes to the same length as
+		// one holding the keys.
+		filter = bloom.New(max(len(keys), 1), b.fpProb)
+	}
+	bd, err := marshalFilter(filter)
+	if err != nil {
+		return SizeEstimate{}, err
+	}
+	h := header{version: ver, bloomLen: len(bd), level0Len: level0Len, level1Len: level1Len}
+	_, _, end := h.offsets(bphw)
+	fingerprintBytes := level1Len * b.fingerprintBits / 8
+	return SizeEstimate{
+		Level0Len:        level0Len,
+		Level1Len:        level1Len,
+		LevelBytes:       (level0Len + level1Len) * bphw,
+		BloomBytes:       len(bd),
+		FingerprintBytes: fingerprintBytes,
+		EncodedBytes:     end + fingerprintBytes + checksumLen,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package mph
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestEstimateSize(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, opts := range [][]Option{
+		{},
+		{WithLoadFactor(0.5), WithFalsePositiveRate(0.001)},
+		{WithPowerOfTwoSizes()},
+		{WithoutBloom()},
+	} {
+		b := NewBuilder(opts...)
+		est, err := b.EstimateSize(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table, err := b.Build(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A build may need a lower load factor, so the estimate is only
+		// exact if it didn't.
+		if diff := est.EncodedBytes - len(data); diff < -len(data)/10 || diff > len(data)/10 {
+			t.Errorf("got EncodedBytes=%d; want about %d", est.EncodedBytes, len(data))
+		}
+		if table.LoadFactor() != b.loadFactor {
+			continue
+		}
+		if est.Level0Len != table.level0Len || est.Level1Len != table.level1Len {
+			t.Errorf("got level arrays of %d and %d; want %d and %d",
+				est.Level0Len, est.Level1Len, table.level0Len, table.level1Len)
+		}
+		if want := (table.level0Len + table.level1Len) * bphw; est.LevelBytes != want {
+			t.Errorf("got LevelBytes=%d; want %d", est.LevelBytes, want)
+		}
+		if est.EncodedBytes != len(data) {
+			t.Errorf("got EncodedBytes=%d; want %d", est.EncodedBytes, len(data))
+		}
+	}
+
+	if _, err := EstimateSize(keys, 0, 0.01); err == nil {
+		t.Error("EstimateSize with load factor 0: got nil error")
+	}
+}
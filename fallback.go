@@ -0,0 +1,52 @@
+package mph
+
+// fallbackBit marks the level0 entries of buckets placed by the fallback
+// search of WithProbingFallback, in tables built with it. The other bits hold
+// the probe with which the bucket was placed.
+const fallbackBit = 1 << 31
+
+// fallbackSeed is the seed of the second hash used by fallbackHash.
+const fallbackSeed = 0x9e3779b9
+
+// fallbackHash returns the hash of s, whose bucket hash is h0, with which the
+// fallback search places s using the given probe. It double hashes: the
+// probe steps h0 by an odd second hash of s, so that keys whose seeded hashes
+// remain clustered whatever the seed still follow distinct sequences, and the
+// step is mixed so that each sequence covers the slots of any level1 length.
+func fallbackHash(h Hasher, h0, probe uint32, s string) uint32 {
+	return fmix32(h0 + probe*(hashString(h, fallbackSeed, s)|1))
+}
+
+// probeBucket is the fallback search for a bucket whose seed search was
+// exhausted. It finds the first probe for which fallbackHash places the keys
+// with the given indices in distinct free slots, and places them there. It
+// reports false if no probe below the seed limit does.
+func probeBucket[I uint32 | uint64](b *Builder, keys []string, vals []int, occ []bool, level1 []I, s *scratch) (uint32, bool) {
+	// Since the search is rare, the hashes aren't kept in s.
+	h0 := make([]uint32, len(vals))
+	h2 := make([]uint32, len(vals))
+	for j, i := range vals {
+		h0[j] = hashString(b.hasher, b.bucketSeed, keys[i])
+		h2[j] = hashString(b.hasher, fallbackSeed, keys[i]) | 1
+	}
+	limit := b.seedLimit()
+tryProbe:
+	for probe := uint32(0); probe < limit; probe++ {
+		s.tmpOcc = s.tmpOcc[:0]
+		for j, i := range vals {
+			n := b.reduction.index(fmix32(h0[j]+probe*h2[j]), len(level1))
+			if occ[n] {
+				for _, n := range s.tmpOcc {
+					occ[n] = false
+					level1[n] = 0
+				}
+				continue tryProbe
+			}
+			occ[n] = true
+			s.tmpOcc = append(s.tmpOcc, n)
+			level1[n] = I(i)
+		}
+		return probe, true
+	}
+	return 0, false
+}
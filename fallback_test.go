@@ -0,0 +1,111 @@
+package mph
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// A clusteredHasher ignores the seed, so that the keys of a bucket land in
+// the same slots whatever the seed, like keys whose hashes are clustered
+// beyond what reseeding can fix.
+type clusteredHasher struct{ fnvHasher }
+
+func (h clusteredHasher) Hash(seed uint32, data []byte) uint32 { return h.fnvHasher.Hash(0, data) }
+func (clusteredHasher) ID() byte                               { return 203 }
+
+func init() {
+	RegisterHasher(clusteredHasher{})
+}
+
+func TestWithProbingFallback(t *testing.T) {
+	keys := make([]string, 200)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	opts := []Option{WithHasher(clusteredHasher{}), WithLoadFactor(0.8), WithMaxSeedAttempts(10000)}
+	// Without the fallback, the load factor is lowered until the keys
+	// happen not to collide, if they ever do.
+	if table, err := NewBuilder(opts...).Build(keys); err == nil && table.LoadFactor() == 0.8 {
+		t.Fatal("Build without fallback: got load factor 0.8")
+	} else if err != nil && !errors.Is(err, ErrBuildFailed) {
+		t.Fatalf("Build without fallback: got err=%v; want nil or one wrapping %v", err, ErrBuildFailed)
+	} else if err == nil {
+		t.Logf("Build without fallback: load factor %v", table.LoadFactor())
+	}
+
+	b := NewBuilder(append(opts, WithProbingFallback())...)
+	table, err := b.Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.LoadFactor() != 0.8 {
+		t.Errorf("Build: got load factor %v; want 0.8", table.LoadFactor())
+	}
+	if stats := table.Stats(); stats.FallbackBuckets == 0 {
+		t.Error("Stats: got no fallback buckets")
+	}
+	if err := table.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(table) {
+		t.Error("UnmarshalBinary: decoded table differs from the encoded one")
+	}
+	ns, _ := decoded.LookupAll(keys)
+	for i, key := range keys {
+		if n, ok := decoded.Lookup(key); !ok || n != uint32(i) {
+			t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+		if ns[i] != uint32(i) {
+			t.Fatalf("LookupAll: got %d for %s; want %d", ns[i], key, i)
+		}
+	}
+
+	table64, err := b.Build64(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = table64.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	var decoded64 Table64
+	if err := decoded64.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		if n, ok := decoded64.Lookup(key); !ok || n != uint64(i) {
+			t.Fatalf("Table64.Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+}
+
+func TestWithProbingFallback_unused(t *testing.T) {
+	// Tables which need no fallback are placed exactly as without it.
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := NewBuilder().Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withFallback, err := NewBuilder(WithProbingFallback()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := withFallback.Stats().FallbackBuckets; n != 0 {
+		t.Errorf("Stats: got %d fallback buckets; want 0", n)
+	}
+	withFallback.fallback = false
+	if !withFallback.Equal(table) {
+		t.Error("Build with fallback placed keys differently")
+	}
+}
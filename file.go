@@ -0,0 +1,61 @@
+package mph
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+)
+
+// syncFile commits the contents of f to stable storage. Tests replace it to
+// simulate failures.
+var syncFile = (*os.File).Sync
+
+// SaveToFile writes t to the file at path, in the encoding produced by
+// MarshalBinary, with mode 0644. An existing file is replaced atomically: t is
+// written to a temporary file in the same directory, which is synced and then
+// renamed to path, so that readers, and the file after a crash, see either
+// the old table or the complete new one. On error, path is left untouched.
+func (t *Table) SaveToFile(path string) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	w := bufio.NewWriter(f)
+	if _, err := t.WriteTo(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := syncFile(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadFromFile reads the table in the file at path, as written by SaveToFile
+// or MarshalBinary. Unlike OpenMmap, the table is decoded into memory, so it
+// doesn't depend on the file once loaded.
+func LoadFromFile(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := new(Table)
+	if err := t.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,75 @@
+package mph
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveToFile(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	table, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "table")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(table) {
+		t.Error("LoadFromFile: loaded table differs from the saved one")
+	}
+	for i, key := range keys {
+		if n, ok := loaded.Lookup(key); !ok || n != uint32(i) {
+			t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("ReadDir after SaveToFile: got %v, %v; want only the table", entries, err)
+	}
+}
+
+func TestSaveToFile_failure(t *testing.T) {
+	table, err := Build([]string{"foo", "bar", "baz"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "table")
+	old, err := Build([]string{"quux"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := old.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	errSync := errors.New("sync failed")
+	defer func(f func(*os.File) error) { syncFile = f }(syncFile)
+	syncFile = func(*os.File) error { return errSync }
+	if err := table.SaveToFile(path); err != errSync {
+		t.Fatalf("SaveToFile: got err=%v; want %v", err, errSync)
+	}
+	loaded, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(old) {
+		t.Error("failed SaveToFile replaced the existing table")
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("ReadDir after failed SaveToFile: got %v, %v; want only the old table", entries, err)
+	}
+	if _, err := LoadFromFile(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadFromFile of a missing file: got err=%v; want one wrapping %v", err, os.ErrNotExist)
+	}
+}
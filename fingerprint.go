@@ -0,0 +1,55 @@
+package mph
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// fingerprintSeed is the seed of the hash whose top bits are the fingerprints
+// of tables built WithFingerprint.
+const fingerprintSeed = 0x85ebca6b
+
+// fingerprint returns the fingerprint of s, which is folded, in t.
+func (t *Table) fingerprint(s string) uint16 {
+	return uint16(hashString(t.hasher, fingerprintSeed, s) >> (32 - t.fingerprintBits))
+}
+
+// fingerprintAt returns the fingerprint stored for level1 slot i.
+func (t *Table) fingerprintAt(i int) uint16 {
+	if t.fingerprintBits == 8 {
+		return uint16(t.fingerprints[i])
+	}
+	return binary.LittleEndian.Uint16(t.fingerprints[i*bpqw:])
+}
+
+// setFingerprints stores the fingerprint of each of keys, which are folded,
+// in its slot of t, whose fingerprintBits must be set.
+func (t *Table) setFingerprints(keys []string) {
+	width := t.fingerprintBits / 8
+	t.fingerprints = make([]byte, t.level1Len*width)
+	for _, key := range keys {
+		i, fp := t.slot(key), t.fingerprint(key)
+		if width == 1 {
+			t.fingerprints[i] = byte(fp)
+		} else {
+			binary.LittleEndian.PutUint16(t.fingerprints[i*bpqw:], fp)
+		}
+	}
+}
+
+// fingerprintBits returns the width of the fingerprints recorded in h's
+// flags, in bits, or 0 if there are none.
+func (h *header) fingerprintBits() (int, error) {
+	switch width := h.flags & fingerprintWidth >> fingerprintShift; width {
+	case 0, 1, 2:
+		return int(width) * 8, nil
+	default:
+		return 0, fmt.Errorf("%w: fingerprints of %d bytes", ErrInvalidTable, width)
+	}
+}
+
+// fingerprintsLen returns the length of the fingerprints encoded after
+// level1 by a table with header h.
+func (h *header) fingerprintsLen() int {
+	return h.level1Len * int(h.flags&fingerprintWidth>>fingerprintShift)
+}
@@ -0,0 +1,224 @@
+package mph
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestWithFingerprint(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	const misses = 200000
+	rates := make(map[int]float64)
+	for _, bits := range []int{8, 16} {
+		for _, loadFactor := range []float32{1.0, 0.5} {
+			table, err := NewBuilder(WithFingerprint(bits), WithLoadFactor(loadFactor)).Build(keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if table.Filter() != nil {
+				t.Errorf("%d bits: got a bloom filter", bits)
+			}
+			want := 1 / float64(uint(1)<<bits)
+			if got := table.FalsePositiveRate(); got != want {
+				t.Errorf("%d bits: FalsePositiveRate: got %v; want %v", bits, got, want)
+			}
+			if n := len(table.fingerprints); n != table.level1Len*bits/8 {
+				t.Errorf("%d bits: got %d bytes of fingerprints for %d slots", bits, n, table.level1Len)
+			}
+			data, err := table.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			compact, err := table.MarshalCompact()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded, aliased, decodedCompact, read Table
+			if err := decoded.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			if err := aliased.unmarshal(data, true); err != nil {
+				t.Fatal(err)
+			}
+			if err := decodedCompact.UnmarshalBinary(compact); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := read.ReadFrom(bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+			for _, tbl := range []*Table{table, &decoded, &aliased, &decodedCompact, &read, table.Clone()} {
+				if !tbl.Equal(table) {
+					t.Errorf("%d bits: decoded table differs from the encoded one", bits)
+				}
+				if err := tbl.Verify(); err != nil {
+					t.Error(err)
+				}
+				ns, oks := tbl.LookupAll(keys)
+				for i, key := range keys {
+					if n, ok := tbl.Lookup(key); !ok || n != uint32(i) || ns[i] != n || !oks[i] {
+						t.Fatalf("%d bits: Lookup(%s): got (%d, %t); want (%d, true)", bits, key, n, ok, i)
+					}
+				}
+			}
+			var buf bytes.Buffer
+			if _, err := table.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				t.Errorf("%d bits: WriteTo and MarshalBinary differ", bits)
+			}
+
+			var found int
+			others := make([]string, misses)
+			for i := range others {
+				others[i] = "other-" + strconv.Itoa(i)
+				if table.Contains(others[i]) {
+					found++
+				}
+			}
+			_, oks := table.LookupAll(others)
+			for i, s := range others {
+				if _, ok := table.Lookup(s); ok != oks[i] {
+					t.Fatalf("%d bits: Lookup(%s) and LookupAll disagree", bits, s)
+				}
+			}
+			rate := float64(found) / misses
+			t.Logf("%d bits, load factor %v: false positive rate %.6f, want %.6f", bits, loadFactor, rate, want)
+			// Allow five standard deviations of the number found.
+			if tol := 5 * math.Sqrt(want/misses); rate > want+tol || rate < want-tol {
+				t.Errorf("%d bits, load factor %v: got false positive rate %.6f; want about %.6f",
+					bits, loadFactor, rate, want)
+			}
+			rates[bits] = rate
+		}
+	}
+	if rates[16] >= rates[8] {
+		t.Errorf("got false positive rate %v with 16 bits; want less than the %v of 8 bits", rates[16], rates[8])
+	}
+}
+
+func TestWithFingerprint_errors(t *testing.T) {
+	keys := []string{"foo", "bar", "baz"}
+	for _, bits := range []int{-8, 1, 12, 32} {
+		if _, err := NewBuilder(WithFingerprint(bits)).Build(keys); !errors.Is(err, ErrInvalidFingerprintBits) {
+			t.Errorf("WithFingerprint(%d): got %v; want ErrInvalidFingerprintBits", bits, err)
+		}
+	}
+	if _, err := NewBuilder(WithFingerprint(8)).Build64(keys); err == nil {
+		t.Error("Build64 WithFingerprint: got nil error")
+	}
+
+	// The later of WithFingerprint and WithoutBloom wins.
+	table, err := NewBuilder(WithFingerprint(8), WithoutBloom()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.fingerprints != nil || table.FalsePositiveRate() != 1 {
+		t.Error("WithoutBloom after WithFingerprint: got fingerprints")
+	}
+
+	table, err = NewBuilder(WithFingerprint(16)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var table64 Table64
+	if err := table64.UnmarshalBinary(data); err == nil {
+		t.Error("Table64.UnmarshalBinary of fingerprints: got nil error")
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data[:len(data)-checksumLen-1]); !errors.Is(err, ErrShortData) {
+		t.Errorf("UnmarshalBinary of truncated fingerprints: got %v; want ErrShortData", err)
+	}
+}
+
+func TestWithSlotFingerprint(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	const (
+		fpProb = 0.05
+		misses = 200000
+	)
+	bloomOnly, err := NewBuilder(WithFalsePositiveRate(fpProb)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := NewBuilder(WithFalsePositiveRate(fpProb), WithSlotFingerprint(8)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Filter() == nil || len(table.fingerprints) != table.level1Len {
+		t.Fatalf("got filter %v and %d bytes of fingerprints; want both", table.Filter(), len(table.fingerprints))
+	}
+	if got, want := table.FalsePositiveRate(), fpProb/256; got != want {
+		t.Errorf("FalsePositiveRate: got %v; want %v", got, want)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded, read Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range []*Table{table, &decoded, &read, table.Clone()} {
+		if !tbl.Equal(table) {
+			t.Error("decoded table differs from the encoded one")
+		}
+		if err := tbl.Verify(); err != nil {
+			t.Error(err)
+		}
+		ns, oks := tbl.LookupAll(keys)
+		for i, key := range keys {
+			if n, ok := tbl.Lookup(key); !ok || n != uint32(i) || ns[i] != n || !oks[i] {
+				t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+	}
+
+	// Only the strings which pass the filter are checked against the
+	// fingerprints, so the rates multiply.
+	others := make([]string, misses)
+	for i := range others {
+		others[i] = "other-" + strconv.Itoa(i)
+	}
+	var bloomFound, found int
+	_, oks := table.LookupAll(others)
+	for i, s := range others {
+		if bloomOnly.Contains(s) {
+			bloomFound++
+		}
+		_, ok := table.Lookup(s)
+		if ok {
+			found++
+		}
+		if ok != oks[i] || ok != table.Contains(s) {
+			t.Fatalf("Lookup(%s), LookupAll, and Contains disagree", s)
+		}
+	}
+	bloomRate, rate := float64(bloomFound)/misses, float64(found)/misses
+	want := bloomRate / 256
+	t.Logf("false positive rate %.6f with the filter alone, %.6f with 8-bit fingerprints, want %.6f",
+		bloomRate, rate, want)
+	if bloomRate < fpProb/2 || bloomRate > 2*fpProb {
+		t.Errorf("filter alone: got false positive rate %.6f; want about %v", bloomRate, fpProb)
+	}
+	// Allow five standard deviations of the number found.
+	if tol := 5 * math.Sqrt(want/misses); rate > want+tol {
+		t.Errorf("with fingerprints: got false positive rate %.6f; want about %.6f", rate, want)
+	}
+}
@@ -0,0 +1,64 @@
+package mph
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// A folding describes how keys are made canonical before they are hashed and
+// added to the bloom filter, and so how the strings looked up must be too.
+type folding struct {
+	caseFold bool
+	// norm is 1 plus the norm.Form set using WithNormalization, or 0.
+	norm byte
+}
+
+// maxNorm is the largest valid folding.norm, that of norm.NFKD.
+const maxNorm = byte(norm.NFKD) + 1
+
+// apply returns s made canonical: normalized, then lowercased.
+func (f folding) apply(s string) string {
+	if f.norm != 0 {
+		s = norm.Form(f.norm - 1).String(s)
+	}
+	if f.caseFold {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// applyAll returns keys made canonical by apply. It returns keys itself if f
+// leaves every string unchanged.
+func (f folding) applyAll(keys []string) []string {
+	if f == (folding{}) {
+		return keys
+	}
+	folded := make([]string, len(keys))
+	for i, key := range keys {
+		folded[i] = f.apply(key)
+	}
+	return folded
+}
+
+// flags returns the header flags which record f.
+func (f folding) flags() uint64 {
+	flags := uint64(f.norm) << normShift
+	if f.caseFold {
+		flags |= flagCaseFold
+	}
+	return flags
+}
+
+// folding returns the folding recorded in h's flags.
+func (h *header) folding() (folding, error) {
+	f := folding{
+		caseFold: h.flags&flagCaseFold != 0,
+		norm:     byte(h.flags & normBits >> normShift),
+	}
+	if f.norm > maxNorm {
+		return f, errors.New("mph.UnmarshalBinary: unknown normalization form")
+	}
+	return f, nil
+}
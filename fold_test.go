@@ -0,0 +1,159 @@
+package mph
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestWithCaseFold(t *testing.T) {
+	keys := []string{"foo", "Bar", "BAZ", "École", "ΣΟΦΙΑ", "straße"}
+	b := NewBuilder(WithCaseFold())
+	table, err := b.Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded, read Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	table64, err := b.Build64(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = table64.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	var decoded64 Table64
+	if err := decoded64.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		s string
+		n uint32
+	}{
+		{"foo", 0}, {"FOO", 0}, {"fOo", 0},
+		{"bar", 1}, {"BAR", 1},
+		{"baz", 2}, {"Baz", 2},
+		{"école", 3}, {"ÉCOLE", 3},
+		{"σοφια", 4}, {"ΣοφΙα", 4},
+		{"straße", 5}, {"STRAßE", 5},
+	} {
+		for _, tbl := range []*Table{table, &decoded, &read} {
+			if n, ok := tbl.Lookup(tt.s); !ok || n != tt.n {
+				t.Errorf("Lookup(%q): got (%d, %t); want (%d, true)", tt.s, n, ok, tt.n)
+			}
+			if n, ok := tbl.LookupBytes([]byte(tt.s)); !ok || n != tt.n {
+				t.Errorf("LookupBytes(%q): got (%d, %t); want (%d, true)", tt.s, n, ok, tt.n)
+			}
+			if !tbl.Contains(tt.s) {
+				t.Errorf("Contains(%q): got false", tt.s)
+			}
+			if ns, oks := tbl.LookupAll([]string{tt.s}); !oks[0] || ns[0] != tt.n {
+				t.Errorf("LookupAll(%q): got (%d, %t); want (%d, true)", tt.s, ns[0], oks[0], tt.n)
+			}
+		}
+		for _, tbl := range []*Table64{table64, &decoded64} {
+			if n, ok := tbl.Lookup(tt.s); !ok || n != uint64(tt.n) {
+				t.Errorf("Table64.Lookup(%q): got (%d, %t); want (%d, true)", tt.s, n, ok, tt.n)
+			}
+		}
+	}
+	// Lowercasing maps rune by rune, so "SS" is not "ß".
+	if table.Contains("STRASSE") {
+		t.Error(`Contains("STRASSE"): got true`)
+	}
+
+	if _, err := b.Build([]string{"foo", "bar", "FOO"}); err == nil {
+		t.Error("Build of keys differing only in case: got nil error")
+	} else if dupErr := (*DuplicateKeyError)(nil); !errors.As(err, &dupErr) || dupErr.Index != 2 {
+		t.Errorf("Build of keys differing only in case: got err=%v; want a *DuplicateKeyError at index 2", err)
+	}
+
+	// Without the option, case matters.
+	plain, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Contains("FOO") {
+		t.Error(`Contains("FOO") without WithCaseFold: got true`)
+	}
+}
+
+func TestWithNormalization(t *testing.T) {
+	// Each key is given in NFC, with precomposed letters, and looked up
+	// in NFD, with combining accents, and the other way around.
+	nfc := []string{"café", "über", "niño"}
+	nfd := []string{"cafe\u0301", "u\u0308ber", "nin\u0303o"}
+	for _, form := range []norm.Form{norm.NFC, norm.NFD} {
+		for _, keys := range [][]string{nfc, nfd} {
+			queries := nfd
+			if keys[0] == nfd[0] {
+				queries = nfc
+			}
+			b := NewBuilder(WithNormalization(form))
+			table, err := b.Build(keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := table.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded Table
+			if err := decoded.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			table64, err := b.Build64(keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, q := range queries {
+				for _, tbl := range []*Table{table, &decoded} {
+					if n, ok := tbl.Lookup(q); !ok || n != uint32(i) {
+						t.Errorf("form %d: Lookup(%+q): got (%d, %t); want (%d, true)", form, q, n, ok, i)
+					}
+					if n, ok := tbl.Lookup(keys[i]); !ok || n != uint32(i) {
+						t.Errorf("form %d: Lookup(%+q): got (%d, %t); want (%d, true)", form, keys[i], n, ok, i)
+					}
+				}
+				if n, ok := table64.Lookup(q); !ok || n != uint64(i) {
+					t.Errorf("form %d: Table64.Lookup(%+q): got (%d, %t); want (%d, true)", form, q, n, ok, i)
+				}
+			}
+		}
+	}
+
+	if _, err := NewBuilder(WithNormalization(norm.NFC)).Build([]string{nfc[0], nfd[0]}); err == nil {
+		t.Error("Build of keys differing only in normal form: got nil error")
+	}
+	table, err := NewBuilder(WithNormalization(norm.NFC), WithCaseFold()).Build(nfc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := table.Lookup("CAFÉ"); !ok || n != 0 {
+		t.Errorf("Lookup with WithCaseFold too: got (%d, %t); want (0, true)", n, ok)
+	}
+	if _, err := NewBuilder(WithNormalization(norm.Form(9))).Build(nfc); err == nil {
+		t.Error("Build with an unknown normal form: got nil error")
+	}
+
+	// Without the option, the forms are different keys.
+	plain, err := Build(nfc, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Contains(nfd[0]) {
+		t.Errorf("Contains(%+q) without WithNormalization: got true", nfd[0])
+	}
+}
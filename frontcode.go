@@ -0,0 +1,83 @@
+package mph
+
+import "fmt"
+
+// frontBlock is the number of keys in each block of front-coded keys. The
+// first key of a block is stored whole, so that a key can be reconstructed
+// from at most frontBlock stored suffixes.
+const frontBlock = 16
+
+// BuildWithFrontCodedKeys is like BuildWithKeys but stores each key as the
+// length of the prefix it shares with the previous key followed by the rest
+// of it, which takes much less space for keys with long common prefixes, such
+// as paths or URLs, when neighbouring keys share them. Sorting the keys
+// before building makes the most of this. In return, Key, Keys, and
+// LookupExact must reconstruct the keys, so Key allocates.
+func BuildWithFrontCodedKeys(keys []string, loadFactor float32, fpProb float64) (*Table, error) {
+	t, err := Build(keys, loadFactor, fpProb)
+	if err != nil {
+		return nil, err
+	}
+	t.setFrontCodedKeys(keys)
+	return t, nil
+}
+
+func (t *Table) setFrontCodedKeys(keys []string) {
+	prefixes := make([]int, len(keys))
+	suffixes := make([]string, len(keys))
+	for i, key := range keys {
+		if i%frontBlock != 0 {
+			prefixes[i] = commonPrefixLen(keys[i-1], key)
+		}
+		suffixes[i] = key[prefixes[i]:]
+	}
+	t.setKeys(suffixes)
+	t.keyPrefixes = prefixes
+}
+
+// commonPrefixLen returns the length of the longest common prefix of a and b.
+func commonPrefixLen(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// storedKey returns what is stored for key n: the key itself or, if the keys
+// are front-coded, its suffix.
+func (t *Table) storedKey(n int) string {
+	var start int
+	if n > 0 {
+		start = t.keyEnds[n-1]
+	}
+	return t.keyData[start:t.keyEnds[n]]
+}
+
+// frontCodedKey reconstructs the front-coded key n from the start of its
+// block.
+func (t *Table) frontCodedKey(n int) string {
+	var key []byte
+	for i := n - n%frontBlock; i <= n; i++ {
+		key = append(key[:t.keyPrefixes[i]], t.storedKey(i)...)
+	}
+	return string(key)
+}
+
+// checkPrefixes reports whether the shared prefix lengths of front-coded keys
+// whose suffixes end at ends are consistent: zero at the start of each block,
+// and elsewhere no longer than the previous key.
+func checkPrefixes(prefixes, ends []int) error {
+	var prevLen, start int
+	for i, p := range prefixes {
+		if i%frontBlock == 0 && p != 0 || p > prevLen {
+			return fmt.Errorf("%w: stored key %d shares %d bytes with the previous key, of %d",
+				ErrInvalidTable, i, p, prevLen)
+		}
+		prevLen = p + ends[i] - start
+		start = ends[i]
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package mph
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestBuildWithFrontCodedKeys(t *testing.T) {
+	var keys []string
+	for _, dir := range []string{"cmd", "internal/storage", "internal/storage/index", "vendor/github.com/example/lib"} {
+		for i := 0; i < 250; i++ {
+			keys = append(keys, fmt.Sprintf("/src/project/%s/file_%03d.go", dir, i))
+		}
+	}
+	keys = append(keys, "", "/", "/src/project/cmd") // empty, and prefixes of the previous keys
+	table, err := BuildWithFrontCodedKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verbatim, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	verbatimData, err := verbatim.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keyBytes int
+	for _, key := range keys {
+		keyBytes += len(key)
+	}
+	if saved := len(verbatimData) - len(data); saved < keyBytes/2 {
+		t.Errorf("MarshalBinary: got %d bytes, %d fewer than with verbatim keys of %d bytes; want at least %d fewer",
+			len(data), saved, keyBytes, keyBytes/2)
+	}
+	if table.MemoryUsage() >= verbatim.MemoryUsage() {
+		t.Errorf("MemoryUsage: got %d; want less than %d with verbatim keys",
+			table.MemoryUsage(), verbatim.MemoryUsage())
+	}
+
+	var decoded, read Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := table.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("WriteTo and MarshalBinary differ")
+	}
+	for _, tbl := range []*Table{table, &decoded, &read, table.Clone()} {
+		if !tbl.Equal(table) {
+			t.Error("got a table different from the original")
+		}
+		if tbl.Equal(verbatim) {
+			t.Error("front-coded table is equal to the verbatim one")
+		}
+		if err := tbl.Verify(); err != nil {
+			t.Error(err)
+		}
+		for i, key := range keys {
+			if got, ok := tbl.Key(uint32(i)); !ok || got != key {
+				t.Fatalf("Key(%d): got (%q, %t); want (%q, true)", i, got, ok, key)
+			}
+			if n, ok := tbl.LookupExact(key); !ok || n != uint32(i) {
+				t.Fatalf("LookupExact(%q): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+		if got := tbl.Keys(); len(got) != len(keys) || got[len(keys)-1] != keys[len(keys)-1] {
+			t.Errorf("Keys: got %d keys ending in %q", len(got), got[len(got)-1])
+		}
+		if _, ok := tbl.LookupExact("/src/project/cmd/file_999.go"); ok {
+			t.Error("LookupExact of an absent key: got found")
+		}
+	}
+
+	// A prefix longer than the previous key is rejected.
+	table.keyPrefixes[1] = len(keys[0]) + 1
+	if data, err = table.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.UnmarshalBinary(data); err == nil {
+		t.Error("UnmarshalBinary of a bad prefix length: got nil error")
+	}
+	if _, err := read.ReadFrom(bytes.NewReader(data)); err == nil {
+		t.Error("ReadFrom of a bad prefix length: got nil error")
+	}
+	if err := table.Verify(); err == nil {
+		t.Error("Verify of a bad prefix length: got nil error")
+	}
+}
@@ -0,0 +1,108 @@
+package mph
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// A Hasher is a family of 32-bit hash functions indexed by a seed. A Table
+// uses seed 0 to assign keys to buckets and a per-bucket seed to place them.
+// Since concurrent lookups into a Table call Hash concurrently, it must be
+// safe for concurrent use.
+type Hasher interface {
+	// Hash returns the hash of data using the given seed. It must not
+	// modify or retain data.
+	Hash(seed uint32, data []byte) uint32
+	// ID identifies the hash function in serialized tables. IDs below 128
+	// are reserved for Hashers provided by this package.
+	ID() byte
+}
+
+// Murmur3 is the default Hasher, using the 32-bit Murmur3 hash function.
+type Murmur3 struct{}
+
+func (Murmur3) Hash(seed uint32, data []byte) uint32 {
+	return murmurSeed(seed).hash(unsafeString(data))
+}
+
+func (Murmur3) ID() byte { return 0 }
+
+var (
+	hashersMu sync.RWMutex
+	hashers   = map[byte]Hasher{Murmur3{}.ID(): Murmur3{}, XXHash{}.ID(): XXHash{}}
+)
+
+// minCustomHasherID is the lowest ID of a Hasher not provided by this
+// package.
+const minCustomHasherID = 128
+
+// RegisterHasher makes h available for decoding tables which were built with
+// it. It panics if h's ID is reserved for this package's Hashers, or if a
+// Hasher with the same ID is already registered.
+func RegisterHasher(h Hasher) {
+	if h.ID() < minCustomHasherID {
+		panic(fmt.Sprintf("mph: hasher ID %d is reserved", h.ID()))
+	}
+	hashersMu.Lock()
+	defer hashersMu.Unlock()
+	if _, dup := hashers[h.ID()]; dup {
+		panic(fmt.Sprintf("mph: hasher ID %d registered twice", h.ID()))
+	}
+	hashers[h.ID()] = h
+}
+
+// lookupHasher returns the registered Hasher with the given ID, or nil for
+// Murmur3.
+func lookupHasher(id byte) (Hasher, error) {
+	if id == (Murmur3{}).ID() {
+		return nil, nil
+	}
+	hashersMu.RLock()
+	h, ok := hashers[id]
+	hashersMu.RUnlock()
+	if !ok {
+		return nil, errors.New("mph.UnmarshalBinary: table uses an unregistered hasher")
+	}
+	return h, nil
+}
+
+// checkHasher returns an error wrapping ErrHasherID if the ID of h, which
+// a table built with it records, is reserved for this package's Hashers, or
+// belongs to a registered Hasher of a different type, since the table would
+// then be decoded with the wrong hash function.
+func checkHasher(h Hasher) error {
+	if k, ok := h.(keyedHasher); ok {
+		h = k.h
+	}
+	if h == nil {
+		return nil
+	}
+	hashersMu.RLock()
+	registered, ok := hashers[h.ID()]
+	hashersMu.RUnlock()
+	if ok && reflect.TypeOf(registered) != reflect.TypeOf(h) {
+		return fmt.Errorf("%w: %d belongs to %T", ErrHasherID, h.ID(), registered)
+	}
+	if !ok && h.ID() < minCustomHasherID {
+		return fmt.Errorf("%w: %d is reserved", ErrHasherID, h.ID())
+	}
+	return nil
+}
+
+// hasherID returns the ID of h, or of Murmur3 if h is nil.
+func hasherID(h Hasher) byte {
+	if h == nil {
+		return Murmur3{}.ID()
+	}
+	return h.ID()
+}
+
+// hashString returns the hash of s using h, or Murmur3 if h is nil.
+func hashString(h Hasher, seed uint32, s string) uint32 {
+	if h == nil {
+		return murmurSeed(seed).hash(s)
+	}
+	return h.Hash(seed, unsafeBytes(s))
+}
@@ -0,0 +1,132 @@
+package mph
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// fnvHasher is a Hasher for testing which is registered by init.
+type fnvHasher struct{}
+
+func (fnvHasher) Hash(seed uint32, data []byte) uint32 {
+	h := fnv.New32a()
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], seed)
+	h.Write(b[:])
+	h.Write(data)
+	return h.Sum32()
+}
+
+func (fnvHasher) ID() byte { return 200 }
+
+// unregisteredHasher is never registered.
+type unregisteredHasher struct{ fnvHasher }
+
+func (unregisteredHasher) ID() byte { return 201 }
+
+// reservedHasher claims the ID of XXHash, and impostorHasher that of
+// fnvHasher.
+type (
+	reservedHasher struct{ fnvHasher }
+	impostorHasher struct{ fnvHasher }
+)
+
+func (reservedHasher) ID() byte { return XXHash{}.ID() }
+func (impostorHasher) ID() byte { return fnvHasher{}.ID() }
+
+func init() {
+	RegisterHasher(fnvHasher{})
+}
+
+func TestWithHasher(t *testing.T) {
+	var keys []string
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	table, err := NewBuilder(WithHasher(fnvHasher{})).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.hasher != (fnvHasher{}) {
+		t.Errorf("decoded hasher: got %#v; want fnvHasher", decoded.hasher)
+	}
+	for _, tbl := range []*Table{table, &decoded} {
+		for i, key := range keys {
+			if n, ok := tbl.Lookup(key); !ok || int(n) != i {
+				t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+	}
+
+	// Murmur3 is the default, and selecting it explicitly changes nothing.
+	want, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewBuilder(WithHasher(Murmur3{})).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.hasher != nil || !slices.Equal(got.level0, want.level0) || !slices.Equal(got.level1, want.level1) {
+		t.Error("building with Murmur3 differs from the default")
+	}
+}
+
+func TestUnmarshalBinary_unregisteredHasher(t *testing.T) {
+	table, err := NewBuilder(WithHasher(unregisteredHasher{})).Build([]string{"a", "b", "c", "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err == nil {
+		t.Error("UnmarshalBinary with an unregistered hasher: got nil error")
+	}
+}
+
+func TestWithHasher_takenID(t *testing.T) {
+	for _, h := range []Hasher{reservedHasher{}, impostorHasher{}} {
+		if _, err := NewBuilder(WithHasher(h)).Build([]string{"a", "b"}); !errors.Is(err, ErrHasherID) {
+			t.Errorf("Build WithHasher(%T) with ID %d: got err=%v; want ErrHasherID", h, h.ID(), err)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterHasher(%T) with ID %d: did not panic", h, h.ID())
+				}
+			}()
+			RegisterHasher(h)
+		}()
+	}
+	// The hashers which own the IDs can still be used.
+	for _, h := range []Hasher{XXHash{}, fnvHasher{}} {
+		if _, err := NewBuilder(WithHasher(h)).Build([]string{"a", "b"}); err != nil {
+			t.Errorf("Build WithHasher(%T): %v", h, err)
+		}
+	}
+}
+
+func TestMurmur3Hasher(t *testing.T) {
+	for _, tt := range murmurTestCases {
+		got := Murmur3{}.Hash(uint32(tt.seed), []byte(tt.input))
+		if got != tt.want {
+			t.Errorf("Murmur3.Hash(0x%x, %q): got 0x%x; want 0x%x",
+				tt.seed, tt.input, got, tt.want)
+		}
+	}
+}
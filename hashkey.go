@@ -0,0 +1,116 @@
+package mph
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Seeds of the hashes from which WithHashKey derives the salt and the ID of
+// a hash key.
+const (
+	hashKeySaltSeed = 0xc2b2ae35
+	hashKeyIDSeed   = 0x27d4eb2f
+)
+
+// A keyedHasher is the Hasher of a table built WithHashKey. It salts the
+// seeds of the table's Hasher, which is nil for Murmur3, with a value derived
+// from the hash key, so that every hash of the table depends on the key.
+type keyedHasher struct {
+	h    Hasher
+	salt uint32
+	id   uint32 // recorded in the encoding to find the key again
+}
+
+func (k keyedHasher) Hash(seed uint32, data []byte) uint32 {
+	return hashString(k.h, seed^k.salt, unsafeString(data))
+}
+
+// ID returns the ID of the salted Hasher, which is recorded as the table's.
+func (k keyedHasher) ID() byte { return hasherID(k.h) }
+
+// newKeyedHasher returns the keyedHasher which salts h with key.
+func newKeyedHasher(h Hasher, key []byte) keyedHasher {
+	s := unsafeString(key)
+	return keyedHasher{h: h, salt: murmurSeed(hashKeySaltSeed).hash(s), id: murmurSeed(hashKeyIDSeed).hash(s)}
+}
+
+// WithHashKey makes the bucketing and placement of keys depend on a secret
+// key, such as random bytes chosen once per process, so that an attacker who
+// chooses the keys of a table cannot predict which of them collide and craft
+// inputs which make Build exhaust its seed search. Every hash of the table,
+// including those of its level0 buckets, is computed with seeds salted by a
+// 32-bit value derived from the key. The encoding records only an ID of the
+// key, and tables built with a key can only be decoded once it is
+// registered with RegisterHashKey. Lookups into such tables are somewhat
+// slower, as Murmur3 is then called through the Hasher interface.
+//
+// The salt hides the seeds but not the hash function itself: keys crafted to
+// collide whatever the seed, as can be done for Murmur3, still collide. For
+// such adversaries, use a Hasher which is a keyed pseudorandom function, or
+// WithProbingFallback. A nil or empty key builds tables without one.
+func WithHashKey(key []byte) Option {
+	return func(b *Builder) {
+		if len(key) == 0 {
+			b.hashKey = nil
+			return
+		}
+		k := newKeyedHasher(nil, key)
+		b.hashKey = &k
+	}
+}
+
+var (
+	hashKeysMu sync.RWMutex
+	hashKeys   = map[uint32]uint32{} // salts by the IDs of the keys
+)
+
+// RegisterHashKey makes key available for decoding the tables which were
+// built WithHashKey(key). Registering a key again has no effect. It panics if
+// a different key with the same ID is already registered, which happens for
+// a pair of keys with a probability of 2^-32.
+func RegisterHashKey(key []byte) {
+	k := newKeyedHasher(nil, key)
+	hashKeysMu.Lock()
+	defer hashKeysMu.Unlock()
+	if salt, dup := hashKeys[k.id]; dup && salt != k.salt {
+		panic(fmt.Sprintf("mph: hash key ID %#x registered twice", k.id))
+	}
+	hashKeys[k.id] = k.salt
+}
+
+// setHasher records hasher, the Hasher of a table, in h.
+func (h *header) setHasher(hasher Hasher) {
+	if k, ok := hasher.(keyedHasher); ok {
+		h.flags |= flagHashKey
+		h.keyID = k.id
+		hasher = k.h
+	}
+	if hasher != nil {
+		h.flags |= uint64(hasher.ID()) << hasherShift
+	}
+}
+
+// hasher returns the Hasher of the table with header h: the registered
+// Hasher with the ID in its flags, salted with the registered hash key if
+// the table was built WithHashKey.
+func (h *header) hasher() (Hasher, error) {
+	hasher, err := lookupHasher(byte(h.flags >> hasherShift))
+	if err != nil || h.flags&flagHashKey == 0 {
+		return hasher, err
+	}
+	hashKeysMu.RLock()
+	salt, ok := hashKeys[h.keyID]
+	hashKeysMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: no key with ID %#x is registered", ErrHashKeyRequired, h.keyID)
+	}
+	return keyedHasher{h: hasher, salt: salt, id: h.keyID}, nil
+}
+
+// sameHasher reports whether the Hashers h and g, either of which may be nil
+// for Murmur3, hash alike, as far as their IDs and hash keys tell.
+func sameHasher(h, g Hasher) bool {
+	kh, _ := h.(keyedHasher)
+	kg, _ := g.(keyedHasher)
+	return hasherID(h) == hasherID(g) && kh.salt == kg.salt && kh.id == kg.id
+}
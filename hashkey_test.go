@@ -0,0 +1,127 @@
+package mph
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestWithHashKey(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	key1, key2 := []byte("test hash key 1"), []byte("test hash key 2")
+	plain, err := NewBuilder().Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table1, err := NewBuilder(WithHashKey(key1)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table2, err := NewBuilder(WithHashKey(key2)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slots := func(table *Table) []int {
+		var slots []int
+		for _, key := range keys {
+			slots = append(slots, table.slot(key))
+		}
+		return slots
+	}
+	if slices.Equal(slots(table1), slots(table2)) || slices.Equal(slots(table1), slots(plain)) {
+		t.Error("tables built with different hash keys place the keys alike")
+	}
+	for _, table := range []*Table{table1, table2} {
+		for i, key := range keys {
+			if n, ok := table.Lookup(key); !ok || n != uint32(i) {
+				t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+	}
+	again, err := NewBuilder(WithHashKey(key1)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Equal(table1) {
+		t.Error("tables built with the same hash key differ")
+	}
+	if table1.Equal(table2) || table1.Equal(plain) {
+		t.Error("Equal: got true for tables built with different hash keys")
+	}
+
+	// Decoding needs the key to be registered.
+	data, err := table1.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); !errors.Is(err, ErrHashKeyRequired) {
+		t.Fatalf("UnmarshalBinary without the key: got err=%v; want one wrapping %v", err, ErrHashKeyRequired)
+	}
+	if _, err := decoded.ReadFrom(bytes.NewReader(data)); !errors.Is(err, ErrHashKeyRequired) {
+		t.Fatalf("ReadFrom without the key: got err=%v; want one wrapping %v", err, ErrHashKeyRequired)
+	}
+	RegisterHashKey(key1)
+	RegisterHashKey(key1)
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	var read Table
+	if _, err := read.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range []*Table{&decoded, &read} {
+		if !tbl.Equal(table1) {
+			t.Error("decoded table differs from the encoded one")
+		}
+		for i, key := range keys {
+			if n, ok := tbl.Lookup(key); !ok || n != uint32(i) {
+				t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+	}
+
+	// The key salts other Hashers too, and Table64s.
+	table, err := NewBuilder(WithHashKey(key1), WithHasher(XXHash{}), WithProbingFallback()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = table.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(table) || hasherID(decoded.hasher) != (XXHash{}).ID() {
+		t.Error("decoded XXHash table differs from the encoded one")
+	}
+	table64, err := NewBuilder(WithHashKey(key2)).Build64(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = table64.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	var decoded64 Table64
+	if err := decoded64.UnmarshalBinary(data); !errors.Is(err, ErrHashKeyRequired) {
+		t.Fatalf("Table64.UnmarshalBinary without the key: got err=%v; want one wrapping %v", err, ErrHashKeyRequired)
+	}
+	RegisterHashKey(key2)
+	if err := decoded64.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		if n, ok := decoded64.Lookup(key); !ok || n != uint64(i) {
+			t.Fatalf("Table64.Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+
+	if b := NewBuilder(WithHashKey(key1), WithHashKey(nil)); b.hasher != nil {
+		t.Errorf("WithHashKey(nil): got hasher %T; want none", b.hasher)
+	}
+}
@@ -0,0 +1,67 @@
+package mph
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// A TableInt is like a Table but its keys are uint64s, such as numeric IDs,
+// which are hashed and added to the bloom filter as their 8 little-endian
+// bytes rather than formatted as strings. Its encoding is that of a Table
+// whose keys are those bytes.
+type TableInt struct {
+	t Table
+}
+
+// BuildInt is like Build but builds a TableInt from integer keys.
+func BuildInt(keys []uint64, loadFactor float32, fpProb float64) (*TableInt, error) {
+	return NewBuilder(WithLoadFactor(loadFactor), WithFalsePositiveRate(fpProb)).BuildInt(keys)
+}
+
+// BuildInt builds a TableInt from keys. Since the keys are not text,
+// WithCaseFold and WithNormalization are ignored. A repeated key is reported
+// by a *DuplicateKeyError whose Key is the decimal form of the integer.
+func (b *Builder) BuildInt(keys []uint64) (*TableInt, error) {
+	// The keys share a single buffer rather than each having its own.
+	buf := make([]byte, len(keys)*bpw)
+	strs := make([]string, len(keys))
+	for i, k := range keys {
+		key := buf[i*bpw : (i+1)*bpw]
+		binary.LittleEndian.PutUint64(key, k)
+		strs[i] = unsafeString(key)
+	}
+	bb := *b
+	bb.folding = folding{}
+	t, err := bb.Build(strs)
+	var dupErr *DuplicateKeyError
+	if errors.As(err, &dupErr) {
+		dupErr.Key = strconv.FormatUint(keys[dupErr.Index], 10)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &TableInt{t: *t}, nil
+}
+
+// LookupInt searches for k in t and returns its index and whether it was
+// found, like Table.Lookup.
+func (t *TableInt) LookupInt(k uint64) (n uint32, ok bool) {
+	var key [bpw]byte
+	binary.LittleEndian.PutUint64(key[:], k)
+	return t.t.Lookup(unsafeString(key[:]))
+}
+
+// Len returns the number of keys in t.
+func (t *TableInt) Len() int {
+	return t.t.Len()
+}
+
+func (t *TableInt) MarshalBinary() ([]byte, error) {
+	return t.t.MarshalBinary()
+}
+
+// UnmarshalBinary decodes a TableInt encoded by MarshalBinary.
+func (t *TableInt) UnmarshalBinary(data []byte) error {
+	return t.t.UnmarshalBinary(data)
+}
@@ -0,0 +1,57 @@
+package mph
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestBuildInt(t *testing.T) {
+	keys := make([]uint64, 10000)
+	for i := range keys {
+		keys[i] = uint64(i) * 0x9e3779b97f4a7c15
+	}
+	keys[1] = math.MaxUint64
+	table, err := BuildInt(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Len() != len(keys) {
+		t.Errorf("Len: got %d; want %d", table.Len(), len(keys))
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded TableInt
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range []*TableInt{table, &decoded} {
+		seen := make([]bool, len(keys))
+		for i, k := range keys {
+			n, ok := tbl.LookupInt(k)
+			if !ok || n != uint32(i) {
+				t.Fatalf("LookupInt(%d): got (%d, %t); want (%d, true)", k, n, ok, i)
+			}
+			if seen[n] {
+				t.Fatalf("LookupInt(%d): index %d is taken", k, n)
+			}
+			seen[n] = true
+		}
+	}
+	var falsePositives int
+	for i := 0; i < len(keys); i++ {
+		if _, ok := table.LookupInt(uint64(i)*0x9e3779b97f4a7c15 + 1); ok {
+			falsePositives++
+		}
+	}
+	if falsePositives > len(keys)/20 {
+		t.Errorf("LookupInt: got %d false positives among %d absent keys", falsePositives, len(keys))
+	}
+
+	var dupErr *DuplicateKeyError
+	if _, err := BuildInt([]uint64{1, 2, 1}, 1.0, 0.01); !errors.As(err, &dupErr) || dupErr.Key != "1" || dupErr.Index != 2 {
+		t.Errorf("BuildInt of repeated keys: got err=%v; want a *DuplicateKeyError for key 1 at index 2", err)
+	}
+}
@@ -0,0 +1,28 @@
+package mph
+
+// Iterate calls fn for each occupied level1 slot of t, in slot order, with
+// the index which Lookup returns for the key placed there, until fn returns
+// false. Every slot of a minimal table, built at a load factor of 1, is
+// occupied. The empty slots of sparser tables hold the same index as the
+// slot of the key with index 0, or, for tables built with BuildWithIndices,
+// of the first key in sorted order, so Iterate can only tell them apart by
+// finding the slot of that key: for sparse tables which were not built with
+// BuildWithKeys or BuildWithFrontCodedKeys, it visits the empty slots too.
+func (t *Table) Iterate(fn func(slot int, index uint32) bool) {
+	if t.numKeys == 0 && t.keyEnds != nil {
+		return
+	}
+	first := -1 // the slot of key 0 if the empty slots are known
+	if t.numKeys < t.level1Len && len(t.keyEnds) > 0 {
+		key, _ := t.Key(0)
+		first = t.slot(t.folding.apply(key))
+	}
+	for i, n := range t.level1 {
+		if first >= 0 && i != first && n == t.level1[first] {
+			continue
+		}
+		if !fn(i, n) {
+			return
+		}
+	}
+}
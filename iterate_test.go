@@ -0,0 +1,67 @@
+package mph
+
+import "testing"
+
+func TestIterate(t *testing.T) {
+	keys := []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta"}
+	for _, loadFactor := range []float32{1.0, 0.5} {
+		table, err := BuildWithKeys(keys, loadFactor, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loadFactor < 1 && table.level1Len == len(keys) {
+			t.Fatalf("load factor %g: got a minimal table", loadFactor)
+		}
+		seen := make(map[uint32]int)
+		prev := -1
+		table.Iterate(func(slot int, index uint32) bool {
+			if slot <= prev || table.level1[slot] != index {
+				t.Errorf("load factor %g: got slot %d, index %d after slot %d", loadFactor, slot, index, prev)
+			}
+			prev = slot
+			seen[index]++
+			return true
+		})
+		if len(seen) != len(keys) {
+			t.Errorf("load factor %g: visited indices %v; want 0 to %d", loadFactor, seen, len(keys)-1)
+		}
+		for i, key := range keys {
+			if seen[uint32(i)] != 1 {
+				t.Errorf("load factor %g: index %d of %s visited %d times; want once",
+					loadFactor, i, key, seen[uint32(i)])
+			}
+		}
+
+		var visits int
+		table.Iterate(func(int, uint32) bool {
+			visits++
+			return visits < 3
+		})
+		if visits != 3 {
+			t.Errorf("load factor %g: got %d visits after fn returned false; want 3", loadFactor, visits)
+		}
+	}
+
+	// Without stored keys, the empty slots of a sparse table are visited.
+	table, err := Build(keys, 0.5, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var visits int
+	table.Iterate(func(int, uint32) bool {
+		visits++
+		return true
+	})
+	if visits != table.level1Len {
+		t.Errorf("sparse table without keys: got %d visits; want all %d slots", visits, table.level1Len)
+	}
+
+	empty, err := BuildWithKeys(nil, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty.Iterate(func(slot int, index uint32) bool {
+		t.Errorf("empty table: visited slot %d with index %d", slot, index)
+		return true
+	})
+}
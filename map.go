@@ -0,0 +1,101 @@
+package mph
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// A Map is an immutable map from strings to values of type V. It stores the
+// values in index order and uses a Table to find the index of a key.
+type Map[V any] struct {
+	table  *Table
+	values []V
+}
+
+// BuildMap builds a Map containing pairs. The loadFactor and fpProb arguments
+// are as in Build.
+func BuildMap[V any](pairs map[string]V, loadFactor float32, fpProb float64) (*Map[V], error) {
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	table, err := Build(keys, loadFactor, fpProb)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]V, len(keys))
+	for i, key := range keys {
+		values[i] = pairs[key]
+	}
+	return &Map[V]{table: table, values: values}, nil
+}
+
+// Get returns the value for key and whether it was found. Like Table.Lookup, a
+// key which is not in m is detected using the bloom filter, so Get may
+// (rarely) return an arbitrary value and true for such a key.
+func (m *Map[V]) Get(key string) (V, bool) {
+	n, ok := m.table.Lookup(key)
+	if !ok || int(n) >= len(m.values) {
+		var zero V
+		return zero, false
+	}
+	return m.values[n], true
+}
+
+// Len returns the number of entries in m.
+func (m *Map[V]) Len() int {
+	return len(m.values)
+}
+
+// A ValueCodec describes a fixed-size binary encoding of values of type V.
+type ValueCodec[V any] struct {
+	// Size is the number of bytes in an encoded value.
+	Size int
+	// Encode writes v to b, which has length Size.
+	Encode func(b []byte, v V)
+	// Decode reads a value from b, which has length Size.
+	Decode func(b []byte) V
+}
+
+// Marshal encodes m using c to encode its values.
+func (m *Map[V]) Marshal(c ValueCodec[V]) ([]byte, error) {
+	td, err := m.table.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	start := bpw + len(td)
+	data := make([]byte, start+len(m.values)*c.Size)
+	binary.LittleEndian.PutUint64(data, uint64(len(td)))
+	copy(data[bpw:], td)
+	for i, v := range m.values {
+		c.Encode(data[start+i*c.Size:start+(i+1)*c.Size], v)
+	}
+	return data, nil
+}
+
+// UnmarshalMap decodes a Map encoded by Marshal using c to decode its values.
+func UnmarshalMap[V any](data []byte, c ValueCodec[V]) (*Map[V], error) {
+	if len(data) < bpw {
+		return nil, fmt.Errorf("%w for the table length", ErrShortData)
+	}
+	tableLen := binary.LittleEndian.Uint64(data)
+	if tableLen > uint64(len(data)-bpw) {
+		return nil, fmt.Errorf("%w for the table", ErrShortData)
+	}
+	start := bpw + int(tableLen)
+	table := new(Table)
+	if err := table.UnmarshalBinary(data[bpw:start]); err != nil {
+		return nil, err
+	}
+	if len(data)-start != table.Len()*c.Size {
+		return nil, errors.New("mph.UnmarshalMap: bad value data length")
+	}
+	values := make([]V, table.Len())
+	for i := range values {
+		values[i] = c.Decode(data[start+i*c.Size : start+(i+1)*c.Size])
+	}
+	return &Map[V]{table: table, values: values}, nil
+}
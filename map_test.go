@@ -0,0 +1,47 @@
+package mph
+
+import (
+	"encoding/binary"
+	"strconv"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	pairs := make(map[string]uint64)
+	for i := 0; i < 1000; i++ {
+		pairs[strconv.Itoa(i)] = uint64(i) * 7
+	}
+	m, err := BuildMap(pairs, 1.0, 1e-9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec := ValueCodec[uint64]{
+		Size:   8,
+		Encode: func(b []byte, v uint64) { binary.LittleEndian.PutUint64(b, v) },
+		Decode: func(b []byte) uint64 { return binary.LittleEndian.Uint64(b) },
+	}
+	data, err := m.Marshal(codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalMap(data, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mm := range []*Map[uint64]{m, decoded} {
+		if got := mm.Len(); got != len(pairs) {
+			t.Errorf("Len: got %d; want %d", got, len(pairs))
+		}
+		for key, want := range pairs {
+			if got, ok := mm.Get(key); !ok || got != want {
+				t.Errorf("Get(%s): got (%d, %t); want (%d, true)", key, got, ok, want)
+			}
+		}
+		for i := 1000; i < 2000; i++ {
+			key := strconv.Itoa(i)
+			if got, ok := mm.Get(key); ok || got != 0 {
+				t.Errorf("Get(%s): got (%d, %t); want (0, false)", key, got, ok)
+			}
+		}
+	}
+}
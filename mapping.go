@@ -0,0 +1,34 @@
+package mph
+
+// BuildWithMapping is like Build but also returns the index which the table
+// assigns to each key: element i is the index of keys[i]. For Build, whose
+// keys are distinct, that is i itself, but a Builder configured WithDedup
+// gives a repeated key the index of its first occurrence.
+func BuildWithMapping(keys []string, loadFactor float32, fpProb float64) (*Table, []uint32, error) {
+	return NewBuilder(WithLoadFactor(loadFactor), WithFalsePositiveRate(fpProb)).BuildWithMapping(keys)
+}
+
+// BuildWithMapping builds a Table from keys and returns the index of each
+// key as described by the package-level BuildWithMapping. Only the repeated
+// keys of a Builder configured WithDedup are looked up to find their index.
+func (b *Builder) BuildWithMapping(keys []string) (*Table, []uint32, error) {
+	t, err := b.Build(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	mapping := make([]uint32, len(keys))
+	var repeat []bool
+	if b.dedup {
+		repeat = repeats(b.folding.applyAll(keys))
+	}
+	var n uint32
+	for i, key := range keys {
+		if repeat != nil && repeat[i] {
+			mapping[i] = t.LookupIndex(key)
+			continue
+		}
+		mapping[i] = n
+		n++
+	}
+	return t, mapping, nil
+}
@@ -0,0 +1,45 @@
+package mph
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestBuildWithMapping(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, mapping, err := BuildWithMapping(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mapping) != len(keys) {
+		t.Fatalf("got a mapping of %d keys; want %d", len(mapping), len(keys))
+	}
+	for i, key := range keys {
+		if n, ok := table.Lookup(key); !ok || n != mapping[i] || n != uint32(i) {
+			t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true), as mapped", key, n, ok, mapping[i])
+		}
+	}
+	if _, _, err := BuildWithMapping(append(keys, "7"), 1.0, 0.01); !errors.As(err, new(*DuplicateKeyError)) {
+		t.Errorf("BuildWithMapping with a repeated key: got err=%v; want *DuplicateKeyError", err)
+	}
+
+	// With WithDedup, repeats map to the index of their first occurrence.
+	repeated := []string{"b", "a", "B", "c", "A", "b", "d"}
+	table, mapping, err = NewBuilder(WithDedup(), WithCaseFold()).BuildWithMapping(repeated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint32{0, 1, 0, 2, 1, 0, 3}; !slices.Equal(mapping, want) {
+		t.Errorf("WithDedup: got mapping %v; want %v", mapping, want)
+	}
+	for i, key := range repeated {
+		if n, ok := table.Lookup(key); !ok || n != mapping[i] {
+			t.Errorf("Lookup(%s): got (%d, %t); want (%d, true), as mapped", key, n, ok, mapping[i])
+		}
+	}
+}
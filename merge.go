@@ -0,0 +1,92 @@
+package mph
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Merge builds a Table over the union of the keys of tables, which must have
+// been built with BuildWithKeys or BuildWithFrontCodedKeys and have disjoint
+// keys. Since tables cannot be combined structurally, Merge rebuilds from the
+// stored keys, like BuildWithKeys with a load factor of 1.0 and a false
+// positive rate of 0.01, so the result also stores its keys. The keys of
+// tables[0] keep their indices and those of each later table follow the keys
+// of the tables before it; MergeWithOffsets returns where each table starts.
+//
+// Merge returns an error wrapping ErrNoKeys if a table has no stored keys, or
+// one wrapping a *DuplicateKeyError for a key which is in more than one of
+// the tables.
+func Merge(tables ...*Table) (*Table, error) {
+	t, _, err := MergeWithOffsets(tables...)
+	return t, err
+}
+
+// MergeWithOffsets is like Merge but also returns the index mapping: the key
+// with index n in tables[i] has index offsets[i]+n in the merged Table.
+func MergeWithOffsets(tables ...*Table) (t *Table, offsets []uint32, err error) {
+	offsets = make([]uint32, len(tables))
+	var numKeys uint64
+	for i, table := range tables {
+		if table.keyEnds == nil {
+			return nil, nil, fmt.Errorf("%w: table %d", ErrNoKeys, i)
+		}
+		offsets[i] = uint32(numKeys)
+		numKeys += uint64(len(table.keyEnds))
+	}
+	if err := checkKeyCount(numKeys); err != nil {
+		return nil, nil, err
+	}
+	keys := make([]string, 0, numKeys)
+	for _, table := range tables {
+		for n := range table.keyEnds {
+			key, _ := table.Key(uint32(n))
+			keys = append(keys, key)
+		}
+	}
+	if t, err = BuildWithKeys(keys, 1.0, 0.01); err != nil {
+		var dup *DuplicateKeyError
+		if errors.As(err, &dup) {
+			err = overlapError(tables, offsets, dup)
+		}
+		return nil, nil, err
+	}
+	return t, offsets, nil
+}
+
+// overlapError describes dup, found among the concatenated keys of tables,
+// by the tables which contain dup.Key.
+func overlapError(tables []*Table, offsets []uint32, dup *DuplicateKeyError) error {
+	j := len(offsets) - 1
+	for uint32(dup.Index) < offsets[j] {
+		j--
+	}
+	for i := 0; i < j; i++ {
+		if _, ok := tables[i].LookupExact(dup.Key); ok {
+			return fmt.Errorf("mph: tables %d and %d both contain key %q: %w", i, j, dup.Key, dup)
+		}
+	}
+	return dup
+}
+
+// WithAdded builds a Table over the keys of t, which must have been built
+// with BuildWithKeys or BuildWithFrontCodedKeys, and keys. The keys of t keep
+// their indices, and those of keys which are not in t follow in order,
+// ignoring repeats, as BuildFromReader does. Like t, the result stores its
+// keys, front-coded if t's are, so that keys may be added to it in turn.
+func (t *Table) WithAdded(keys []string, loadFactor float32, fpProb float64) (*Table, error) {
+	if t.keyEnds == nil {
+		return nil, ErrNoKeys
+	}
+	all := newDistinctKeys(len(t.keyEnds) + len(keys))
+	for n := range t.keyEnds {
+		key, _ := t.Key(uint32(n))
+		all.add(key)
+	}
+	for _, key := range keys {
+		all.add(key)
+	}
+	if t.keyPrefixes != nil {
+		return BuildWithFrontCodedKeys(all.keys, loadFactor, fpProb)
+	}
+	return BuildWithKeys(all.keys, loadFactor, fpProb)
+}
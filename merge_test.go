@@ -0,0 +1,120 @@
+package mph
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	keys := make([]string, 300)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	a, err := BuildWithKeys(keys[:100], 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := BuildWithFrontCodedKeys(keys[100:], 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, offsets, err := MergeWithOffsets(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offsets) != 2 || offsets[0] != 0 || offsets[1] != 100 {
+		t.Errorf("offsets: got %v; want [0 100]", offsets)
+	}
+	if merged.Len() != len(keys) {
+		t.Errorf("Len: got %d; want %d", merged.Len(), len(keys))
+	}
+	for i, table := range []*Table{a, b} {
+		for j, key := range table.Keys() {
+			want := offsets[i] + uint32(j)
+			if n, ok := merged.LookupExact(key); !ok || n != want {
+				t.Fatalf("LookupExact(%s): got (%d, %t); want (%d, true)", key, n, ok, want)
+			}
+		}
+	}
+	if _, ok := merged.LookupExact("key-300"); ok {
+		t.Error("LookupExact of a missing key: got true")
+	}
+	if err := merged.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	// Merged tables store their keys, so they can be merged again.
+	c, err := BuildWithKeys([]string{"x", "y"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged, err = Merge(merged, c); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := merged.LookupExact("y"); !ok || n != uint32(len(keys)+1) {
+		t.Errorf("LookupExact(y): got (%d, %t); want (%d, true)", n, ok, len(keys)+1)
+	}
+}
+
+func TestMerge_errors(t *testing.T) {
+	a, err := BuildWithKeys([]string{"a", "b", "c"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := BuildWithKeys([]string{"d", "b"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noKeys, err := Build([]string{"e"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Merge(a, noKeys); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("Merge of a table without keys: got %v; want ErrNoKeys", err)
+	}
+	_, err = Merge(a, b)
+	var dup *DuplicateKeyError
+	if !errors.As(err, &dup) || dup.Key != "b" {
+		t.Fatalf("Merge of overlapping tables: got %v; want a DuplicateKeyError for b", err)
+	}
+	if want := `mph: tables 0 and 1 both contain key "b": ` + dup.Error(); err.Error() != want {
+		t.Errorf("Merge of overlapping tables: got %q; want %q", err, want)
+	}
+}
+
+func TestTable_WithAdded(t *testing.T) {
+	keys := []string{"one", "two", "three", "four", "five"}
+	for _, build := range []func([]string, float32, float64) (*Table, error){BuildWithKeys, BuildWithFrontCodedKeys} {
+		table, err := build(keys[:3], 1.0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		grown, err := table.WithAdded([]string{"four", "two", "five", "four"}, 1.0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if grown.Len() != len(keys) {
+			t.Errorf("Len: got %d; want %d", grown.Len(), len(keys))
+		}
+		if (grown.keyPrefixes != nil) != (table.keyPrefixes != nil) {
+			t.Error("WithAdded changed whether the keys are front-coded")
+		}
+		for i, key := range keys {
+			if n, ok := grown.LookupExact(key); !ok || n != uint32(i) {
+				t.Errorf("LookupExact(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+		if _, ok := grown.LookupExact("six"); ok {
+			t.Error("LookupExact of a missing key: got true")
+		}
+	}
+
+	table, err := Build(keys[:3], 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.WithAdded(keys[3:], 1.0, 0.01); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("WithAdded to a table without keys: got %v; want ErrNoKeys", err)
+	}
+}
@@ -0,0 +1,39 @@
+package mph
+
+// OpenMmap opens the table encoded in the file at path, as written by
+// MarshalBinary or WriteTo, by mapping the file into memory. The level arrays
+// and stored keys of the table refer to the mapping rather than to copies of
+// the file's contents, so opening a large table is cheap; the checksum is
+// still verified, which reads the file once.
+//
+// The table is read-only and valid only until Close is called, which releases
+// the mapping. The file should not be modified while it is mapped. On systems
+// without memory mapping, OpenMmap reads the file into memory instead.
+func OpenMmap(path string) (*Table, error) {
+	data, err := mmapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := new(Table)
+	if err := checkLegacy(data); err != nil {
+		munmap(data)
+		return nil, err
+	}
+	if err := t.unmarshal(data, true); err != nil {
+		munmap(data)
+		return nil, err
+	}
+	t.mapping = data
+	return t, nil
+}
+
+// Close releases the memory mapping of a table opened with OpenMmap, after
+// which t must not be used. For other tables Close does nothing.
+func (t *Table) Close() error {
+	if t.mapping == nil {
+		return nil
+	}
+	err := munmap(t.mapping)
+	*t = Table{}
+	return err
+}
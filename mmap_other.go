@@ -0,0 +1,14 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package mph
+
+import "os"
+
+// mmapFile reads the file at path, on systems without memory mapping.
+func mmapFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func munmap(data []byte) error {
+	return nil
+}
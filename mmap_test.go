@@ -0,0 +1,143 @@
+package mph
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+func TestOpenMmap(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux", "a", "bb", "ccc"}
+	table, err := BuildWithKeys(keys, 1.0, 1e-9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "table")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mapped, err := OpenMmap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nativeLittleEndian {
+		start := uintptr(unsafe.Pointer(&mapped.mapping[0]))
+		end := start + uintptr(len(mapped.mapping))
+		for _, level := range [][]uint32{mapped.level0, mapped.level1} {
+			if p := uintptr(unsafe.Pointer(&level[0])); p < start || p >= end {
+				t.Errorf("level array at %#x is not in the mapping [%#x, %#x)", p, start, end)
+			}
+		}
+	}
+	if got := mapped.Len(); got != len(keys) {
+		t.Errorf("Len: got %d; want %d", got, len(keys))
+	}
+	for i, key := range keys {
+		if n, ok := mapped.Lookup(key); !ok || n != uint32(i) {
+			t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+		if got, ok := mapped.Key(uint32(i)); !ok || got != key {
+			t.Errorf("Key(%d): got (%q, %t); want (%q, true)", i, got, ok, key)
+		}
+	}
+	if err := mapped.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mapped.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if err := table.Close(); err != nil {
+		t.Errorf("Close of unmapped table: %v", err)
+	}
+}
+
+func TestOpenMmap_corrupt(t *testing.T) {
+	table, err := Build([]string{"foo", "bar", "baz"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-checksumLen-1] ^= 1
+	dir := t.TempDir()
+	path := filepath.Join(dir, "table")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenMmap(path); err != errChecksum {
+		t.Errorf("OpenMmap of corrupt table: got err=%v; want %v", err, errChecksum)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenMmap(empty); err == nil {
+		t.Error("OpenMmap of empty file: got nil error")
+	}
+	if _, err := OpenMmap(filepath.Join(dir, "missing")); err == nil {
+		t.Error("OpenMmap of missing file: got nil error")
+	}
+}
+
+func TestOpenMmap_reload(t *testing.T) {
+	old, err := Build([]string{"foo", "bar", "baz"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := old.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "table")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{"a", "b", "c", "d"}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = table.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	reloads := map[string]func(*Table) error{
+		"UnmarshalBinary": func(t *Table) error { return t.UnmarshalBinary(data) },
+		"ReadFrom": func(t *Table) error {
+			_, err := t.ReadFrom(bytes.NewReader(data))
+			return err
+		},
+	}
+	for name, reload := range reloads {
+		mapped, err := OpenMmap(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mapping := mapped.mapping
+		if err := reload(mapped); err != nil {
+			t.Fatal(err)
+		}
+		// The mapping is kept, though unused, until Close releases it.
+		if len(mapped.mapping) == 0 || &mapped.mapping[0] != &mapping[0] {
+			t.Errorf("%s into a mapped table dropped the mapping", name)
+		}
+		for i, key := range keys {
+			if n, ok := mapped.Lookup(key); !ok || n != uint32(i) {
+				t.Errorf("%s: Lookup(%s): got (%d, %t); want (%d, true)", name, key, n, ok, i)
+			}
+		}
+		if err := mapped.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if mapped.mapping != nil {
+			t.Errorf("%s: Close kept the mapping", name)
+		}
+	}
+}
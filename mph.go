@@ -2,114 +2,707 @@
 package mph
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"slices"
 	"sort"
+	"strings"
+	"unsafe"
 
 	"github.com/instabid/bloom"
 )
 
 // A Table is an immutable hash table that provides constant-time lookups of key
 // indices using a minimal perfect hash.
+//
+// Once built or decoded, a Table is safe for concurrent use by multiple
+// goroutines, since its lookups, including those of its bloom filter, only
+// read it. The methods which replace its contents, UnmarshalBinary, ReadFrom,
+// GobDecode, Reset, and Close, must not be called concurrently with any
+// other.
 type Table struct {
 	filter    *bloom.Filter
+	hasher    Hasher // nil means Murmur3
+	reduction reduction
 	level0    []uint32
 	level0Len int
 	level1    []uint32
 	level1Len int
+	numKeys   int
+
+	// level0u16 holds the seeds instead of level0, which is then nil, for
+	// tables built WithNarrowSeeds whose seeds all fit in 16 bits.
+	level0u16 []uint16
+
+	// fallback is set for tables built WithProbingFallback, whose level0
+	// entries with fallbackBit set hold fallback probes rather than seeds.
+	fallback bool
+	// ids is set for tables built with BuildWithIndices, whose level1
+	// entries are the caller's indices rather than positions of keys.
+	ids bool
+	// folding is how t's keys, and the strings looked up, are made
+	// canonical, for tables built WithCaseFold or WithNormalization.
+	folding folding
+	// bucketSeed is the seed of the hash which assigns keys to level0
+	// buckets; see WithBucketSeed.
+	bucketSeed uint32
+	// wideHash is set for tables built WithHashBits(64), whose keys are
+	// placed in level1 by 64-bit hashes as computed by hash64.
+	wideHash bool
+
+	// loadFactor is the load factor at which t was built.
+	loadFactor float32
+	// fpProb is the false positive rate of filter, or 0 if unknown.
+	fpProb float64
+
+	// fingerprints holds, for tables built WithFingerprint or
+	// WithSlotFingerprint, the fingerprintBits-bit fingerprint of the key in
+	// each level1 slot, in little-endian order. Only the latter also have a
+	// filter.
+	fingerprints    []byte
+	fingerprintBits int
+
+	// keyData holds the concatenated keys, in index order, for tables built
+	// with BuildWithKeys. keyEnds[i] is the end offset of key i in keyData.
+	keyData string
+	keyEnds []int
+	// keyPrefixes is set for tables built with BuildWithFrontCodedKeys.
+	// Key i is then its first keyPrefixes[i] bytes shared with key i-1
+	// followed by the suffix which keyData holds in its place.
+	keyPrefixes []int
+
+	// build describes how t was built; it is zero for decoded tables.
+	build buildStats
+
+	// mapping is the memory mapping which the level arrays and keyData
+	// refer to, for tables opened with OpenMmap. Reloading the table keeps
+	// it, though nothing refers to it any more, so that Close releases it.
+	mapping []byte
+	// aliased is set for tables decoded by UnmarshalBinaryNoCopy, whose
+	// level arrays may refer to the caller's data and so are not reused.
+	aliased bool
 }
 
+// maxSeedAttempts is the default number of seeds tried for each bucket.
 const maxSeedAttempts = 100000000
 
+// checkKeyCount returns an error wrapping ErrTooManyKeys if a Table cannot
+// hold n keys, since its indices, from 0 to n-1, must fit in 32 bits.
+func checkKeyCount(n uint64) error {
+	if n > math.MaxUint32 {
+		return fmt.Errorf("%w: %d keys, at most %d; use Build64", ErrTooManyKeys, n, uint64(math.MaxUint32))
+	}
+	return nil
+}
+
 // Build builds a Table from keys using the "Hash, displace, and compress"
 // algorithm described in http://cmph.sourceforge.net/papers/esa09.pdf.
+// The keys must be distinct; if a key is repeated, Build returns a
+// *DuplicateKeyError. If no table can be built, the error is a *BuildError,
+// which matches ErrBuildFailed.
+//
+// The loadFactor is the initial ratio of keys to level1 slots, as set by
+// WithLoadFactor; values outside (0, 1] are rejected with
+// ErrInvalidLoadFactor. The fpProb is the false positive rate of the bloom
+// filter and must be in (0, 1), or ErrInvalidFalsePositiveRate is returned.
 func Build(keys []string, loadFactor float32, fpProb float64) (*Table, error) {
-	filter := bloom.New(len(keys), fpProb)
-	for _, key := range keys {
-		filter.Add(key)
+	return NewBuilder(WithLoadFactor(loadFactor), WithFalsePositiveRate(fpProb)).Build(keys)
+}
+
+// BuildContext is like Build but stops early, returning ctx.Err(), if ctx is
+// done before the table is built.
+func BuildContext(ctx context.Context, keys []string, loadFactor float32, fpProb float64) (*Table, error) {
+	return NewBuilder(WithLoadFactor(loadFactor), WithFalsePositiveRate(fpProb)).BuildContext(ctx, keys)
+}
+
+// BuildMarshal is like Build but returns the encoding of the table, as
+// produced by MarshalBinary, rather than the table itself.
+func BuildMarshal(keys []string, loadFactor float32, fpProb float64) ([]byte, error) {
+	t, err := Build(keys, loadFactor, fpProb)
+	if err != nil {
+		return nil, err
 	}
-	if loadFactor > 1.0 || loadFactor == 0.0 {
-		loadFactor = 1.0
+	return t.MarshalBinary()
+}
+
+// BuildBytes is like Build but takes the keys as byte slices. The keys are not
+// copied, so they must not be modified while BuildBytes runs. The resulting
+// Table may be queried with either Lookup or LookupBytes.
+func BuildBytes(keys [][]byte, loadFactor float32, fpProb float64) (*Table, error) {
+	strs := make([]string, len(keys))
+	for i, key := range keys {
+		strs[i] = unsafeString(key)
 	}
-	for {
-		table := buildInternal(keys, loadFactor, filter)
-		if table != nil {
-			return table, nil
-		}
-		loadFactor *= 0.9
-		if loadFactor < 0.1 {
-			return nil, errors.New("Failed creating table")
-		}
+	t, err := Build(strs, loadFactor, fpProb)
+	// The key of a *DuplicateKeyError shares the memory of keys, which may
+	// change once BuildBytes returns.
+	var dup *DuplicateKeyError
+	if errors.As(err, &dup) {
+		dup.Key = strings.Clone(dup.Key)
 	}
+	return t, err
 }
 
-func buildInternal(keys []string, loadFactor float32, filter *bloom.Filter) *Table {
-	tableLen := int(float32(len(keys)) / loadFactor)
-	var (
-		level0        = make([]uint32, tableLen/4)
-		level0Len     = len(level0)
-		level1        = make([]uint32, tableLen)
-		level1Len     = len(level1)
-		sparseBuckets = make([][]int, len(level0))
-		zeroSeed      = murmurSeed(0)
-	)
-	for i, s := range keys {
-		n := int(zeroSeed.hash(s)) % level0Len
-		sparseBuckets[n] = append(sparseBuckets[n], i)
+// BuildWithKeys is like Build but also retains a copy of the keys so that
+// they may be retrieved by index using Key and Keys.
+func BuildWithKeys(keys []string, loadFactor float32, fpProb float64) (*Table, error) {
+	t, err := Build(keys, loadFactor, fpProb)
+	if err != nil {
+		return nil, err
+	}
+	t.setKeys(keys)
+	return t, nil
+}
+
+// BuildWithIndices is like Build but takes the keys together with the
+// indices which Lookup should return for them, such as existing IDs, rather
+// than assigning each key its position. The indices need not be dense or
+// distinct. Since the table doesn't know which indices are in use, Len is
+// only the number of keys, and Verify checks just the table's structure. To
+// find the keys which share an index, use a Builder configured
+// WithCollisionReport.
+func BuildWithIndices(m map[string]uint32, loadFactor float32, fpProb float64) (*Table, error) {
+	return NewBuilder(WithLoadFactor(loadFactor), WithFalsePositiveRate(fpProb)).BuildWithIndices(m)
+}
+
+func (t *Table) setKeys(keys []string) {
+	var size int
+	for _, key := range keys {
+		size += len(key)
+	}
+	var b strings.Builder
+	b.Grow(size)
+	t.keyEnds = make([]int, len(keys))
+	for i, key := range keys {
+		b.WriteString(key)
+		t.keyEnds[i] = b.Len()
 	}
-	var buckets []indexBucket
-	for n, vals := range sparseBuckets {
-		if len(vals) > 0 {
-			buckets = append(buckets, indexBucket{n, vals})
+	t.keyData = b.String()
+}
+
+func (b *Builder) buildInternal(ctx context.Context, keys []string, loadFactor float32, filter *bloom.Filter, spent uint64) (*Table, buildStats, error) {
+	level0, level1, stats, err := placeKeys[uint32](ctx, b, keys, loadFactor, b.wideHash())
+	stats.totalSeedAttempts = spent + stats.seedAttempts
+	if level0 == nil {
+		return nil, stats, err
+	}
+	t := &Table{
+		filter:    filter,
+		hasher:    b.hasher,
+		reduction: b.reduction,
+		level0:    level0,
+		level0Len: len(level0),
+		level1:    level1,
+		level1Len: len(level1),
+		numKeys:   len(keys),
+		fallback:  b.fallback,
+		folding:   b.folding,
+		build:     stats,
+		wideHash:  b.wideHash(),
+
+		bucketSeed: b.bucketSeed,
+		loadFactor: loadFactor,
+		fpProb:     b.filterFPProb(),
+	}
+	if b.narrowSeeds {
+		// Fallback probes have fallbackBit set, so they never fit.
+		if t.level0u16 = narrowSeeds(level0); t.level0u16 != nil {
+			t.level0 = nil
 		}
 	}
-	sort.Sort(bySize(buckets))
+	if b.fingerprintBits != 0 {
+		t.fingerprintBits = b.fingerprintBits
+		t.setFingerprints(keys)
+	}
+	return t, stats, nil
+}
+
+// placeKeys finds a seed for each level0 bucket such that the keys of all the
+// buckets, which must be distinct, land in distinct level1 slots, where the
+// slot of a key is chosen by reducing a hash of the key with that seed to the
+// number of slots: a 64-bit hash as computed by hash64 if wide is set, or a
+// 32-bit one otherwise. It returns the seeds and, for each slot, the index
+// of the key which occupies it, or nil if the seed search is exhausted or,
+// along with its error, if ctx is done. If the search is exhausted, stats
+// counts only the seed attempts made, and records how far the search got.
+func placeKeys[I uint32 | uint64](ctx context.Context, b *Builder, keys []string, loadFactor float32, wide bool) (level0 []uint32, level1 []I, stats buildStats, err error) {
+	s := getScratch()
+	defer putScratch(s)
+	level0Len, level1Len := b.reduction.sizes(len(keys), loadFactor, b.level0Ratio)
+	if err := b.checkMemory(level0Len, level1Len, int(unsafe.Sizeof(I(0)))); err != nil {
+		return nil, nil, stats, err
+	}
+	level0 = make([]uint32, level0Len)
+	level1 = make([]I, level1Len)
+	buckets := b.bucketize(keys, level0Len, b.reduction, s)
 
-	occ := make([]bool, len(level1))
-	var tmpOcc []int
-	for _, bucket := range buckets {
-		var seed murmurSeed
+	occ := resize(s.occ, len(level1))
+	clear(occ)
+	s.occ = occ
+	bh := &s.bh
+	bh.hasher, bh.wide = b.hasher, wide
+	limit := b.seedLimit()
+	done := ctx.Done()
+nextBucket:
+	for bi, bucket := range buckets {
+		if canceled(done) {
+			return nil, nil, stats, ctx.Err()
+		}
+		if b.progress != nil && bi > 0 && bi%progressInterval == 0 {
+			b.progress(bi, len(buckets))
+		}
+		var seed uint32
+		bh.reset(keys, bucket.vals)
 	trySeed:
-		seenKeys := make(map[string]bool)
-		tmpOcc = tmpOcc[:0]
-		for _, i := range bucket.vals {
-			n := int(seed.hash(keys[i])) % level1Len
+		s.tmpOcc = s.tmpOcc[:0]
+		for j, i := range bucket.vals {
+			n := bh.index(b.reduction, seed, j, level1Len)
 			if occ[n] {
-				if _, contains := seenKeys[keys[i]]; !contains {
-					for _, n := range tmpOcc {
-						occ[n] = false
-					}
-					seed++
-					if seed > maxSeedAttempts {
-						return nil
-					}
+				for _, n := range s.tmpOcc {
+					occ[n] = false
+					level1[n] = 0
+				}
+				seed++
+				if seed%cancelInterval == 0 && canceled(done) {
+					return nil, nil, stats, ctx.Err()
+				}
+				if seed < limit {
 					goto trySeed
 				}
+				if b.fallback {
+					if probe, ok := probeBucket(b, keys, bucket.vals, occ, level1, s); ok {
+						level0[int(bucket.n)] = fallbackBit | probe
+						continue nextBucket
+					}
+				}
+				stats = bucketStats(buckets[:bi], level0, b.fallback, limit)
+				stats.seedAttempts += uint64(limit)
+				stats.maxBucketSize = len(buckets[0].vals)
+				stats.placedBuckets, stats.numBuckets, stats.stuckBucketSize = bi, len(buckets), len(bucket.vals)
+				return nil, nil, stats, nil
 			}
 			occ[n] = true
-			tmpOcc = append(tmpOcc, n)
-			level1[n] = uint32(i)
-			seenKeys[keys[i]] = true
+			s.tmpOcc = append(s.tmpOcc, n)
+			level1[n] = I(i)
 		}
-		level0[int(bucket.n)] = uint32(seed)
+		level0[int(bucket.n)] = seed
 	}
+	if b.progress != nil {
+		b.progress(len(buckets), len(buckets))
+	}
+	return level0, level1, bucketStats(buckets, level0, b.fallback, limit), nil
+}
 
-	return &Table{
-		filter:    filter,
-		level0:    level0,
-		level0Len: level0Len,
-		level1:    level1,
-		level1Len: level1Len,
+// progressInterval is the number of buckets placed between calls to the
+// function set by WithProgress.
+const progressInterval = 1024
+
+// cancelInterval is the number of seeds tried for a bucket between checks
+// for the cancellation of a build.
+const cancelInterval = 1 << 16
+
+// canceled reports whether done, the Done channel of a context, is closed.
+func canceled(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// A bucketHasher computes the hashes with which the keys of a bucket are
+// placed. Since a bucket's keys are hashed again for every seed tried, it
+// premixes them once for Murmur3, leaving only the seed-dependent rounds to
+// each attempt.
+type bucketHasher struct {
+	hasher Hasher // nil means Murmur3
+	wide   bool   // compute 64-bit hashes as hash64 does
+
+	keys  []string // the bucket's keys, for other Hashers
+	lens  []int    // the length of each key
+	ends  []int    // the end of each key's premixed blocks in mixed
+	mixed []uint32
+}
+
+// reset prepares h for hashing the keys with the given indices.
+func (h *bucketHasher) reset(keys []string, vals []int) {
+	h.keys, h.lens, h.ends, h.mixed = h.keys[:0], h.lens[:0], h.ends[:0], h.mixed[:0]
+	for _, i := range vals {
+		if h.hasher != nil {
+			h.keys = append(h.keys, keys[i])
+			continue
+		}
+		h.mixed = premix(h.mixed, keys[i])
+		h.lens = append(h.lens, len(keys[i]))
+		h.ends = append(h.ends, len(h.mixed))
 	}
 }
 
+// hash returns the hash of the j'th key using the given seed.
+func (h *bucketHasher) hash(seed uint32, j int) uint64 {
+	if h.hasher != nil {
+		if h.wide {
+			return hash64(h.hasher, seed, h.keys[j])
+		}
+		return uint64(h.hasher.Hash(seed, unsafeBytes(h.keys[j])))
+	}
+	var start int
+	if j > 0 {
+		start = h.ends[j-1]
+	}
+	mixed, l := h.mixed[start:h.ends[j]], h.lens[j]
+	if h.wide {
+		return uint64(murmurSeed(seed).mixedHash(mixed, l))<<32 |
+			uint64(murmurSeed(^seed).mixedHash(mixed, l))
+	}
+	return uint64(murmurSeed(seed).mixedHash(mixed, l))
+}
+
+// index returns the slot of the j'th key among n using the given seed and
+// reduction.
+func (h *bucketHasher) index(r reduction, seed uint32, j, n int) int {
+	if h.wide {
+		return r.index64(h.hash(seed, j), n)
+	}
+	return r.index(uint32(h.hash(seed, j)), n)
+}
+
+// defaultLevel0Ratio is the default ratio of level1 slots to level0 buckets.
+const defaultLevel0Ratio = 4
+
+// tableSizes returns the lengths of the level0 and level1 arrays for a table
+// of numKeys keys built at the given load factor with level0Ratio level1
+// slots per level0 bucket. Neither is less than 1, and there are at least as
+// many level1 slots as keys. The division is in float64, since float32 cannot
+// represent key counts above 2^24 exactly.
+func tableSizes(numKeys int, loadFactor float32, level0Ratio float64) (level0Len, level1Len int) {
+	tableLen := max(int(float64(numKeys)/float64(loadFactor)), numKeys)
+	return max(int(float64(tableLen)/level0Ratio), 1), max(tableLen, 1)
+}
+
+// bucketize assigns each key to one of n level0 buckets, reducing its hash
+// with r, and returns the non-empty buckets, largest first. The keys of each
+// bucket are in increasing order. The buckets are stored in s and valid until
+// it is next used.
+func (b *Builder) bucketize(keys []string, n int, r reduction, s *scratch) []indexBucket {
+	// Count the keys of each bucket, turn the counts into the buckets'
+	// start offsets in vals, and then place each key at its bucket's
+	// offset, which leaves each bounds[j] at the end of bucket j.
+	s.bucketOf = resize(s.bucketOf, len(keys))
+	s.bounds = resize(s.bounds, n)
+	clear(s.bounds)
+	for i, key := range keys {
+		j := r.index(hashString(b.hasher, b.bucketSeed, key), n)
+		s.bucketOf[i] = j
+		s.bounds[j]++
+	}
+	var start int
+	for j, count := range s.bounds {
+		s.bounds[j] = start
+		start += count
+	}
+	s.vals = resize(s.vals, len(keys))
+	for i, j := range s.bucketOf {
+		s.vals[s.bounds[j]] = i
+		s.bounds[j]++
+	}
+	buckets := s.buckets[:0]
+	start = 0
+	for j, end := range s.bounds {
+		if end > start {
+			buckets = append(buckets, indexBucket{j, s.vals[start:end:end]})
+		}
+		start = end
+	}
+	s.buckets = buckets
+	sort.Sort(bySize(buckets))
+	return buckets
+}
+
 // Lookup searches for s in t and returns its index and whether it was found.
+// If t was built WithoutBloom, every s is reported as found, unless t has no
+// keys. A nil or zero Table, such as one whose decoding failed, holds no
+// strings either.
 func (t *Table) Lookup(s string) (n uint32, ok bool) {
-	i0 := int(murmurSeed(0).hash(s)) % t.level0Len
-	seed := t.level0[i0]
-	i1 := int(murmurSeed(seed).hash(s)) % t.level1Len
-	n = t.level1[i1]
-	return n, t.filter.Has(s)
+	if t.empty() {
+		return 0, false
+	}
+	s = t.folding.apply(s)
+	if t.fingerprints != nil {
+		i := t.slot(s)
+		return t.level1[i], t.fingerprintAt(i) == t.fingerprint(s) && (t.filter == nil || t.filter.Has(s))
+	}
+	return t.index(s), t.has(s)
+}
+
+// empty reports whether t holds no keys: it is nil or zero, or it was built
+// from no keys, and its only slot belongs to none of them.
+func (t *Table) empty() bool {
+	return t == nil || t.numKeys == 0
+}
+
+// LookupDefault is like Lookup but returns def if s is not found. Since
+// misses are detected by the bloom filter, with its false positive rate a
+// string not in t gets some key's index instead of def, and if t was built
+// WithoutBloom, def is never returned.
+func (t *Table) LookupDefault(s string, def uint32) uint32 {
+	if n, ok := t.Lookup(s); ok {
+		return n
+	}
+	return def
+}
+
+// LookupIndex returns the index of s, which must be in t, like the n result
+// of Lookup but without consulting the bloom filter or fingerprints. This
+// saves their cost on paths where s is known to be a key, such as when it
+// came from Keys, but the result for any other string is the index of an
+// arbitrary key, with nothing to tell it apart from a hit. LookupIndex
+// returns 0 for a nil or zero Table.
+func (t *Table) LookupIndex(s string) uint32 {
+	if t.empty() {
+		return 0
+	}
+	return t.index(t.folding.apply(s))
+}
+
+// Hash returns the hash with which t assigns s to a level0 bucket, for use
+// with LookupHashed. It depends only on s and on t's Hasher, bucket seed, and
+// folding, so the hash of a string which is looked up repeatedly, in t or in
+// other tables built with the same options, need only be computed once.
+func (t *Table) Hash(s string) uint32 {
+	return hashString(t.hasher, t.bucketSeed, t.folding.apply(s))
+}
+
+// LookupHashed is like Lookup but takes h, which must be t.Hash(s), and so
+// saves one of the two hashes of s which Lookup computes, besides those of
+// the bloom filter. The other, which places s in a level1 slot, uses the seed
+// of s's bucket and cannot be shared: Murmur3 mixes the seed in before the
+// first block, and the part of its work which doesn't depend on the seed
+// costs more to store and reload than to redo for all but the shortest keys.
+func (t *Table) LookupHashed(h uint32, s string) (n uint32, ok bool) {
+	if t.empty() {
+		return 0, false
+	}
+	s = t.folding.apply(s)
+	i := t.hashedSlot(h, s)
+	if t.fingerprints != nil {
+		return t.level1[i], t.fingerprintAt(i) == t.fingerprint(s) && (t.filter == nil || t.filter.Has(s))
+	}
+	return t.level1[i], t.has(s)
+}
+
+// index returns the index which t assigns to s, which is meaningful only if
+// s is in t. Unlike Lookup, it expects s to have been folded already.
+func (t *Table) index(s string) uint32 {
+	return t.level1[t.slot(s)]
+}
+
+// slot returns the level1 slot in which t places s, which is folded.
+func (t *Table) slot(s string) int {
+	return t.hashedSlot(hashString(t.hasher, t.bucketSeed, s), s)
+}
+
+// hashedSlot is like slot but takes the bucket hash h0 of s.
+func (t *Table) hashedSlot(h0 uint32, s string) int {
+	seed := t.seed(t.reduction.index(h0, t.level0Len))
+	if t.fallback && seed&fallbackBit != 0 {
+		return t.reduction.index(fallbackHash(t.hasher, h0, seed&^fallbackBit, s), t.level1Len)
+	}
+	if t.wideHash {
+		return t.reduction.index64(hash64(t.hasher, seed, s), t.level1Len)
+	}
+	return t.reduction.index(hashString(t.hasher, seed, s), t.level1Len)
+}
+
+// Filter returns the bloom filter with which t detects strings which are not
+// in it, or nil if t was built WithoutBloom or WithFingerprint. The filter is
+// shared with t and must not be modified.
+func (t *Table) Filter() *bloom.Filter {
+	return t.filter
+}
+
+// FalsePositiveRate returns the false positive probability with which t's
+// bloom filter was built, or that of its fingerprints if it was built
+// WithFingerprint, or their product if it was built WithSlotFingerprint. It
+// is 1 if t has neither, since then every string is reported as found, and 0
+// if the filter's is unknown because t was built with WithFilter or decoded
+// from an encoding which predates it.
+func (t *Table) FalsePositiveRate() float64 {
+	if t.fingerprints != nil && t.filter == nil {
+		return math.Ldexp(1, -t.fingerprintBits)
+	}
+	if t.filter == nil {
+		return 1
+	}
+	if t.fingerprints != nil {
+		return math.Ldexp(t.fpProb, -t.fingerprintBits)
+	}
+	return t.fpProb
+}
+
+// Contains reports whether s is in t, without computing its index unless t
+// was built WithFingerprint or WithSlotFingerprint. Like the ok result of
+// Lookup, it uses the bloom filter or fingerprints, so it is always true for
+// keys in t but is also true for other strings with their false positive
+// probability. If t was built WithoutBloom, it is always true. Like Lookup,
+// it is false for a nil or zero Table.
+func (t *Table) Contains(s string) bool {
+	if t.empty() {
+		return false
+	}
+	return t.has(t.folding.apply(s))
+}
+
+// has is like Contains but expects s to have been folded already.
+func (t *Table) has(s string) bool {
+	if t.fingerprints != nil && t.fingerprintAt(t.slot(s)) != t.fingerprint(s) {
+		return false
+	}
+	return t.filter == nil || t.filter.Has(s)
+}
+
+// Len returns the number of keys in t.
+func (t *Table) Len() int {
+	return t.numKeys
+}
+
+// LoadFactor returns the load factor at which t was built. This is lower
+// than the one requested if the build had to reduce it to succeed. For tables
+// decoded from encodings which predate it, it is estimated from the number of
+// keys and slots.
+func (t *Table) LoadFactor() float32 {
+	return t.loadFactor
+}
+
+// Key returns the key with index n. It reports false if n is out of range or
+// if t was not built with BuildWithKeys or BuildWithFrontCodedKeys.
+func (t *Table) Key(n uint32) (string, bool) {
+	if t.keyEnds == nil || int64(n) >= int64(len(t.keyEnds)) {
+		return "", false
+	}
+	if t.keyPrefixes != nil {
+		return t.frontCodedKey(int(n)), true
+	}
+	return t.storedKey(int(n)), true
+}
+
+// Keys returns the keys of t in index order, or nil if t was not built with
+// BuildWithKeys or BuildWithFrontCodedKeys.
+func (t *Table) Keys() []string {
+	if t.keyEnds == nil {
+		return nil
+	}
+	keys := make([]string, len(t.keyEnds))
+	for i := range keys {
+		keys[i], _ = t.Key(uint32(i))
+	}
+	return keys
+}
+
+// LookupAll looks up each of keys as Lookup would and returns their indices
+// and whether they were found.
+func (t *Table) LookupAll(keys []string) ([]uint32, []bool) {
+	if t.empty() {
+		return make([]uint32, len(keys)), make([]bool, len(keys))
+	}
+	keys = t.folding.applyAll(keys)
+	ns := make([]uint32, len(keys))
+	oks := make([]bool, len(keys))
+	// Each stage's memory accesses are independent of one another, so
+	// the cache misses of a batch of lookups into a large table overlap
+	// rather than each stalling the next lookup.
+	for i, s := range keys {
+		ns[i] = uint32(t.reduction.index(hashString(t.hasher, t.bucketSeed, s), t.level0Len))
+	}
+	for i, s := range keys {
+		if seed := t.seed(int(ns[i])); t.fallback && seed&fallbackBit != 0 {
+			h := fallbackHash(t.hasher, hashString(t.hasher, t.bucketSeed, s), seed&^fallbackBit, s)
+			ns[i] = uint32(t.reduction.index(h, t.level1Len))
+		} else if t.wideHash {
+			ns[i] = uint32(t.reduction.index64(hash64(t.hasher, seed, s), t.level1Len))
+		} else {
+			ns[i] = uint32(t.reduction.index(hashString(t.hasher, seed, s), t.level1Len))
+		}
+	}
+	if t.fingerprints != nil {
+		for i, s := range keys {
+			oks[i] = t.fingerprintAt(int(ns[i])) == t.fingerprint(s)
+		}
+	}
+	for i := range ns {
+		ns[i] = t.level1[ns[i]]
+	}
+	for i, s := range keys {
+		if t.fingerprints == nil {
+			oks[i] = t.has(s)
+		} else if oks[i] && t.filter != nil {
+			oks[i] = t.filter.Has(s)
+		}
+	}
+	return ns, oks
+}
+
+// LookupExact is like Lookup but, for tables built with BuildWithKeys or
+// BuildWithFrontCodedKeys, reports s as found only if it is the key stored at
+// its index, so there are no false positives. The price is the memory of the
+// stored keys: their total length plus a word per key. For tables without
+// stored keys, LookupExact is the same as Lookup.
+func (t *Table) LookupExact(s string) (n uint32, ok bool) {
+	if t.empty() {
+		return 0, false
+	}
+	if t.keyEnds == nil {
+		return t.Lookup(s)
+	}
+	s = t.folding.apply(s)
+	n = t.index(s)
+	key, ok := t.Key(n)
+	return n, ok && key == s
+}
+
+// LookupN looks up key in each of tables in turn, such as an overlay of
+// recent keys before the base table it overrides, and returns the position
+// among tables of the first which contains key, along with key's index in
+// it. It reports -1 and false if none does. Each table is queried as by
+// LookupExact, so a false positive of an earlier table without stored keys
+// hides key from the later ones. Nil tables, such as overlays which are not
+// loaded yet, contain nothing.
+func LookupN(key string, tables ...*Table) (tableIdx int, n uint32, ok bool) {
+	for i, t := range tables {
+		if t == nil {
+			continue
+		}
+		if n, ok := t.LookupExact(key); ok {
+			return i, n, true
+		}
+	}
+	return -1, 0, false
+}
+
+// LookupBytes is like Lookup but takes the key as a byte slice. It does not
+// allocate, unless t was built WithCaseFold or WithNormalization and that
+// changes b, and it gives the same result as Lookup(string(b)).
+func (t *Table) LookupBytes(b []byte) (n uint32, ok bool) {
+	return t.Lookup(unsafeString(b))
+}
+
+// unsafeString returns a string that shares b's memory. The result must not
+// outlive b or be used after b is modified.
+func unsafeString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// unsafeBytes returns a byte slice that shares s's memory. The result must not
+// be modified.
+func unsafeBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
 }
 
 type indexBucket struct {
@@ -117,69 +710,800 @@ type indexBucket struct {
 	vals []int
 }
 
+// bySize orders buckets from largest to smallest. Buckets of equal size are
+// ordered by index so that builds are reproducible.
 type bySize []indexBucket
 
-func (s bySize) Len() int           { return len(s) }
-func (s bySize) Less(i, j int) bool { return len(s[i].vals) > len(s[j].vals) }
-func (s bySize) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s bySize) Len() int { return len(s) }
+func (s bySize) Less(i, j int) bool {
+	if len(s[i].vals) != len(s[j].vals) {
+		return len(s[i].vals) > len(s[j].vals)
+	}
+	return s[i].n < s[j].n
+}
+func (s bySize) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 
 const word = 64
 const bpw = word >> 3
 const bphw = word >> 4
-const ver = 1
+const bpqw = word >> 5
+const ver = 9
+
+// magic starts the encoding from version magicVersion on, before the version
+// byte, so that data which is not a table can be told apart before it is
+// decoded. Earlier versions start with the version byte.
+const (
+	magic        = "MPH1"
+	magicVersion = 9
+)
+
+// AllowLegacyFormat makes the decoders accept encodings in the versions
+// before 9, which lack the magic prefix. Without it, such data is rejected
+// with ErrNotTable like any other which doesn't start with the magic, which
+// guards against decoding unrelated data. Upgrade accepts the old versions
+// regardless, so the setting is only needed to decode old tables as they
+// are. It should be set before any table is decoded.
+var AllowLegacyFormat bool
+
+// FormatVersion is the version of the binary encoding which MarshalBinary,
+// MarshalCompact, and WriteTo produce. Tables encoded in any earlier version
+// can still be decoded, those before version 9 only with AllowLegacyFormat.
+const FormatVersion = ver
+
+// checksumLen is the length of the CRC-32 (IEEE) of all preceding bytes which
+// ends the encoding from version 4 on.
+const checksumLen = 4
+
+// levelAlign is the alignment, relative to the start of the encoding, of the
+// level arrays from version 5 on. It allows the arrays of a mapped file to be
+// used in place.
+const levelAlign = 8
+
+// maxEncodedLen bounds the lengths which parseHeader accepts for the parts of
+// an encoding, so that the offsets computed from them cannot overflow.
+const maxEncodedLen = math.MaxInt / 32
+
+var errChecksum = errors.New("mph.UnmarshalBinary: checksum mismatch; data is corrupt")
+
+// Bits of the flags header word. The top byte of the word is not a flag but
+// the ID of the table's Hasher.
+const (
+	// flagKeys indicates that the keys are stored after level1, as the
+	// uvarint length of each key followed by the concatenated key bytes.
+	// With flagFrontCoded, these are preceded by the uvarint length of the
+	// prefix which each key shares with the previous one, and hold only the
+	// rest of each key.
+	flagKeys = 1 << iota
+	// flagWideIndex indicates that level1 entries are 8 bytes rather than 4.
+	// Such tables are decoded by Table64.
+	flagWideIndex
+
+	// The two bits from reductionShift hold the table's reduction.
+	reductionShift = 2
+	reductionBits  = 3 << reductionShift
+
+	// flagCompact indicates that the level arrays are encoded as uvarints,
+	// without padding, rather than as fixed-width words; see
+	// MarshalCompact.
+	flagCompact = 1 << 4
+	// flagFallback indicates that the table was built WithProbingFallback.
+	flagFallback = 1 << 5
+	// flagIDs indicates that the table was built with BuildWithIndices.
+	flagIDs = 1 << 6
+	// flagNarrowSeeds indicates that level0 entries are 2 bytes rather than
+	// 4; see WithNarrowSeeds.
+	flagNarrowSeeds = 1 << 7
+	// flagCaseFold indicates that the table was built WithCaseFold.
+	flagCaseFold = 1 << 8
+
+	// The three bits from normShift hold 1 plus the norm.Form with which
+	// the table was built WithNormalization, or 0.
+	normShift = 9
+	normBits  = 7 << normShift
+
+	// flagFrontCoded indicates that the stored keys are front-coded; see
+	// flagKeys.
+	flagFrontCoded = 1 << 12
+
+	// The two bits from fingerprintShift hold the width in bytes of the
+	// fingerprints, stored after level1, of a table built WithFingerprint,
+	// or 0.
+	fingerprintShift = 13
+	fingerprintWidth = 3 << fingerprintShift
+
+	// flagHashKey indicates that the table was built WithHashKey. The ID of
+	// the key is in the high half of the bucket seed word.
+	flagHashKey = 1 << 15
+	// flagWideHash indicates that keys are placed in level1 by 64-bit
+	// hashes; see WithHashBits.
+	flagWideHash = 1 << 16
+	// flagChunked indicates the chunked encoding written by WriteChunked.
+	// The header is followed by its checksum, and each part of the table
+	// is framed by its length and followed by its checksum, with no
+	// padding or overall checksum.
+	flagChunked = 1 << 17
+
+	knownFlags = flagKeys | flagWideIndex | reductionBits | flagCompact | flagFallback | flagIDs |
+		flagNarrowSeeds | flagCaseFold | normBits | flagFrontCoded | fingerprintWidth | flagHashKey |
+		flagWideHash | flagChunked
+	hasherShift = 56
+)
+
+// headerLen returns the size of the fixed-width header which precedes the
+// bloom filter in the given encoding version.
+func headerLen(version byte) int {
+	switch version {
+	case 1:
+		return 1 + 3*bpw
+	case 2:
+		return 1 + 4*bpw
+	case 3, 4, 5:
+		return 1 + 5*bpw
+	case 6:
+		return 1 + 6*bpw
+	case 7:
+		return 1 + 7*bpw
+	case 8:
+		return 1 + 8*bpw
+	}
+	return len(magic) + 1 + 8*bpw
+}
+
+// wordOffset returns the offset of the i'th word of the header in the given
+// encoding version.
+func wordOffset(version byte, i int) int {
+	if version >= magicVersion {
+		return len(magic) + 1 + i*bpw
+	}
+	return 1 + i*bpw
+}
+
+// A header is the fixed-width header of the binary encoding.
+type header struct {
+	version   byte
+	bloomLen  int
+	level0Len int
+	level1Len int
+	numKeys   int    // absent in version 1
+	flags     uint64 // absent before version 3
+
+	// loadFactor is absent before version 6; decoders estimate it with
+	// estimateLoadFactor.
+	loadFactor float32
+	fpProb     float64 // absent before version 7
+	bucketSeed uint32  // absent before version 8
+	keyID      uint32  // the ID of the hash key, with flagHashKey
+}
+
+// offsets returns the offsets at which level0 and level1 start and at which
+// level1 ends in an encoding with header h, given the size of a level1 entry.
+func (h *header) offsets(level1Width int) (off0, off1, end int) {
+	off0 = headerLen(h.version) + h.bloomLen
+	off0 += padLen(h.version, off0)
+	level0Width := bphw
+	if h.flags&flagNarrowSeeds != 0 {
+		level0Width = bpqw
+	}
+	off1 = off0 + h.level0Len*level0Width
+	off1 += padLen(h.version, off1)
+	return off0, off1, off1 + h.level1Len*level1Width
+}
+
+// padLen returns the number of zero bytes which precede a level array that
+// would otherwise start at offset off in the given encoding version.
+func padLen(version byte, off int) int {
+	if version < 5 {
+		return 0
+	}
+	return -off & (levelAlign - 1)
+}
+
+// put encodes h, in the current version, at the start of data.
+func (h *header) put(data []byte) {
+	copy(data, magic)
+	data[len(magic)] = ver
+	w := data[wordOffset(ver, 0):]
+	binary.LittleEndian.PutUint64(w, uint64(h.bloomLen))
+	binary.LittleEndian.PutUint64(w[bpw:], uint64(h.level0Len))
+	binary.LittleEndian.PutUint64(w[2*bpw:], uint64(h.level1Len))
+	binary.LittleEndian.PutUint64(w[3*bpw:], uint64(h.numKeys))
+	binary.LittleEndian.PutUint64(w[4*bpw:], h.flags)
+	binary.LittleEndian.PutUint64(w[5*bpw:], uint64(math.Float32bits(h.loadFactor)))
+	binary.LittleEndian.PutUint64(w[6*bpw:], math.Float64bits(h.fpProb))
+	binary.LittleEndian.PutUint64(w[7*bpw:], uint64(h.keyID)<<32|uint64(h.bucketSeed))
+}
+
+// FormatVersionOf returns the version of the encoding of the table at the
+// start of data, without decoding the table, whether or not
+// AllowLegacyFormat is set. For a version newer than FormatVersion, it
+// returns the version along with an error.
+func FormatVersionOf(data []byte) (byte, error) {
+	return encodingVersion(data)
+}
+
+// encodingVersion returns the version of the encoding at the start of data,
+// which needs to hold only the magic, if any, and the version byte. Data
+// which doesn't start with the magic or, lacking it, with the version byte
+// of an earlier version is not a table. For a version newer than ver, it
+// returns the version along with an error.
+func encodingVersion(data []byte) (byte, error) {
+	if len(data) < 1 {
+		return 0, fmt.Errorf("%w for the header", ErrShortData)
+	}
+	if v := data[0]; v >= 1 && v < magicVersion {
+		return v, nil
+	}
+	if len(data) <= len(magic) && strings.HasPrefix(magic, string(data)) {
+		return 0, fmt.Errorf("%w for the header", ErrShortData)
+	}
+	if len(data) <= len(magic) || string(data[:len(magic)]) != magic {
+		return 0, fmt.Errorf("%w: data does not start with %q", ErrNotTable, magic)
+	}
+	if v := data[len(magic)]; v < magicVersion || v > ver {
+		return v, fmt.Errorf("mph: unknown encoding version %d", v)
+	}
+	return data[len(magic)], nil
+}
+
+// checkLegacy returns an error wrapping ErrNotTable if data starts like an
+// encoding in a version before magicVersion and AllowLegacyFormat is not set.
+func checkLegacy(data []byte) error {
+	if len(data) > 0 && data[0] >= 1 && data[0] < magicVersion && !AllowLegacyFormat {
+		return fmt.Errorf("%w: data lacks the magic %q; encodings of version %d are only decoded with AllowLegacyFormat",
+			ErrNotTable, magic, data[0])
+	}
+	return nil
+}
+
+// parseHeader decodes the header at the start of data, in any version.
+func parseHeader(data []byte) (header, error) {
+	var h header
+	v, err := encodingVersion(data)
+	if err != nil {
+		return h, err
+	}
+	h.version = v
+	if len(data) < headerLen(h.version) {
+		return h, fmt.Errorf("%w for the header", ErrShortData)
+	}
+	w := data[wordOffset(h.version, 0):]
+	lens := []*int{&h.bloomLen, &h.level0Len, &h.level1Len, &h.numKeys}
+	if h.version == 1 {
+		lens = lens[:3] // version 1 has no key count
+	}
+	for i, p := range lens {
+		n := binary.LittleEndian.Uint64(w[i*bpw:])
+		if n > maxEncodedLen {
+			return h, fmt.Errorf("%w: header length %d is too large", ErrInvalidTable, n)
+		}
+		*p = int(n)
+	}
+	if h.level0Len == 0 || h.level1Len == 0 {
+		return h, fmt.Errorf("%w: empty level array", ErrInvalidTable)
+	}
+	if h.numKeys > h.level1Len {
+		return h, fmt.Errorf("%w: %d keys in %d level1 slots", ErrInvalidTable, h.numKeys, h.level1Len)
+	}
+	if h.version >= 3 {
+		h.flags = binary.LittleEndian.Uint64(w[4*bpw:])
+		if h.flags&(1<<hasherShift-1)&^knownFlags != 0 {
+			return h, errors.New("mph.UnmarshalBinary: unknown flags; the table needs a newer version of this package")
+		}
+	}
+	if h.version >= 6 {
+		h.loadFactor = math.Float32frombits(uint32(binary.LittleEndian.Uint64(w[5*bpw:])))
+	}
+	if h.version >= 7 {
+		h.fpProb = math.Float64frombits(binary.LittleEndian.Uint64(w[6*bpw:]))
+	}
+	if h.version >= 8 {
+		seed := binary.LittleEndian.Uint64(w[7*bpw:])
+		if h.flags&flagHashKey != 0 {
+			h.keyID = uint32(seed >> 32)
+			seed &= math.MaxUint32
+		}
+		if seed > math.MaxUint32 {
+			return h, fmt.Errorf("%w: bucket seed %d is too large", ErrInvalidTable, seed)
+		}
+		h.bucketSeed = uint32(seed)
+	}
+	return h, nil
+}
+
+// header returns the header with which t is encoded, given the length of its
+// encoded bloom filter.
+func (t *Table) header(bloomLen int) header {
+	h := header{
+		version:   ver,
+		bloomLen:  bloomLen,
+		level0Len: t.level0Len,
+		level1Len: t.level1Len,
+		numKeys:   t.numKeys,
+
+		loadFactor: t.loadFactor,
+		fpProb:     t.fpProb,
+		bucketSeed: t.bucketSeed,
+	}
+	if t.keyEnds != nil {
+		h.flags |= flagKeys
+	}
+	if t.keyPrefixes != nil {
+		h.flags |= flagFrontCoded
+	}
+	h.setHasher(t.hasher)
+	h.flags |= uint64(t.reduction) << reductionShift
+	if t.fallback {
+		h.flags |= flagFallback
+	}
+	if t.ids {
+		h.flags |= flagIDs
+	}
+	if t.wideHash {
+		h.flags |= flagWideHash
+	}
+	h.flags |= t.folding.flags()
+	if t.level0u16 != nil {
+		h.flags |= flagNarrowSeeds
+	}
+	h.flags |= uint64(t.fingerprintBits/8) << fingerprintShift
+	return h
+}
+
+// reduction returns the reduction recorded in h's flags, after checking that
+// it suits h's level arrays.
+func (h *header) reduction() (reduction, error) {
+	r := reduction(h.flags & reductionBits >> reductionShift)
+	return r, r.check(h.level0Len, h.level1Len)
+}
 
 func (t *Table) MarshalBinary() ([]byte, error) {
-	bd, err := t.filter.MarshalBinary()
+	return t.AppendBinary(nil)
+}
+
+// AppendBinary appends the encoding of t produced by MarshalBinary to b and
+// returns the extended buffer.
+func (t *Table) AppendBinary(b []byte) ([]byte, error) {
+	return t.appendBinary(b, false)
+}
+
+// MarshalCompact is like MarshalBinary but encodes the level arrays as
+// uvarints. Since most seeds are small and level1 entries are below the
+// number of keys, this typically halves their size, but the arrays of a
+// mapped file can no longer be used in place and decoding is slower.
+// UnmarshalBinary, ReadFrom, and OpenMmap accept both encodings.
+func (t *Table) MarshalCompact() ([]byte, error) {
+	return t.appendBinary(nil, true)
+}
+
+func (t *Table) appendBinary(b []byte, compact bool) ([]byte, error) {
+	bd, err := marshalFilter(t.filter)
 	if err != nil {
-		return nil, err
+		return b, err
 	}
-	size := (1+1+1)*bpw + len(bd) + (t.level0Len+t.level1Len)*bphw + 1
-	data := make([]byte, size)
-	data[0] = ver
-	binary.LittleEndian.PutUint64(data[1:], uint64(len(bd)))
-	binary.LittleEndian.PutUint64(data[1+bpw:], uint64(t.level0Len))
-	binary.LittleEndian.PutUint64(data[1+2*bpw:], uint64(t.level1Len))
-	start := 1 + 3*bpw
-	copy(data[start:start+len(bd)], bd)
-	start += len(bd)
-	for i, v := range t.level0 {
-		binary.LittleEndian.PutUint32(data[start+i*bphw:], v)
+	h := t.header(len(bd))
+	base := len(b)
+	if compact {
+		h.flags |= flagCompact
+		size := headerLen(ver) + len(bd)
+		b = slices.Grow(b, size+t.level0Len+2*t.level1Len+checksumLen)[:base+size]
+		h.put(b[base:])
+		copy(b[base+headerLen(ver):], bd)
+		if t.level0u16 != nil {
+			b = appendUvarints(b, t.level0u16)
+		} else {
+			b = appendUvarints(b, t.level0)
+		}
+		b = appendUvarints(b, t.level1)
+		b = append(b, t.fingerprints...)
+	} else {
+		off0, off1, size := h.offsets(bphw)
+		b = slices.Grow(b, size+checksumLen)[:base+size]
+		data := b[base:]
+		clear(data)
+		h.put(data)
+		copy(data[headerLen(ver):], bd)
+		for i, v := range t.level0 {
+			binary.LittleEndian.PutUint32(data[off0+i*bphw:], v)
+		}
+		for i, v := range t.level0u16 {
+			binary.LittleEndian.PutUint16(data[off0+i*bpqw:], v)
+		}
+		for i, v := range t.level1 {
+			binary.LittleEndian.PutUint32(data[off1+i*bphw:], v)
+		}
+		b = append(b, t.fingerprints...)
 	}
-	start += len(t.level0) * bphw
-	for i, v := range t.level1 {
-		binary.LittleEndian.PutUint32(data[start+i*bphw:], v)
+	if h.flags&flagKeys != 0 {
+		for _, p := range t.keyPrefixes {
+			b = binary.AppendUvarint(b, uint64(p))
+		}
+		var prev int
+		for _, end := range t.keyEnds {
+			b = binary.AppendUvarint(b, uint64(end-prev))
+			prev = end
+		}
+		b = append(b, t.keyData...)
 	}
-	return data, nil
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b[base:])), nil
 }
 
+// UnmarshalBinary decodes a Table encoded by MarshalBinary. Data which does
+// not start with the magic of the encoding is rejected with ErrNotTable. If
+// AllowLegacyFormat is set, it also accepts the older encodings which lack
+// the magic: version 1 did not record the number of keys, so for those
+// tables the count is recovered from the largest stored index, versions
+// before 4 have no checksum, versions before 6 did not record the load factor,
+// which is estimated instead, and versions before 7 did not record the false
+// positive rate.
+//
+// The memory of t's level arrays is reused where it is large enough, so t
+// must not be in use by concurrent lookups. If UnmarshalBinary returns an
+// error, t is left empty, as after Reset.
 func (t *Table) UnmarshalBinary(data []byte) error {
-	if len(data) < 1+3*bpw {
-		return errors.New("mph.UnmarshalBinary: data to short. unknown encoding")
+	if err := checkLegacy(data); err != nil {
+		t.Reset()
+		return err
 	}
-	if data[0] != ver {
-		return errors.New("mph.UnmarshalBinary: unknown encoding")
+	return t.unmarshal(data, false)
+}
+
+// UnmarshalBinaryNoCopy is like UnmarshalBinary but, where it can, makes t
+// refer to data rather than copying it: the level arrays, if the host is
+// little-endian and they are suitably aligned in memory, as they are when
+// data is 8-byte aligned, and the stored keys and fingerprints. This makes
+// decoding a large table much cheaper, but data must then not be modified
+// for as long as t is in use. The arrays of compact encodings are always
+// decoded into copies.
+func (t *Table) UnmarshalBinaryNoCopy(data []byte) error {
+	if err := checkLegacy(data); err != nil {
+		t.Reset()
+		return err
 	}
-	bloomFilterLen := int(binary.LittleEndian.Uint64(data[1:]))
-	t.level0Len = int(binary.LittleEndian.Uint64(data[1+bpw:]))
-	t.level1Len = int(binary.LittleEndian.Uint64(data[1+2*bpw:]))
-	if len(data) < (1+1+1)*bpw+bloomFilterLen+(t.level0Len+t.level1Len)*bphw+1 {
-		return errors.New("mph.UnmarshalBinary: data to short. unknown encoding")
+	if err := t.unmarshal(data, true); err != nil {
+		return err
 	}
-	start := 1 + 3*bpw
-	t.filter = new(bloom.Filter)
-	err := t.filter.UnmarshalBinary(data[start : start+bloomFilterLen])
+	t.aliased = true
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder using the encoding of MarshalBinary.
+func (t *Table) GobEncode() ([]byte, error) {
+	return t.MarshalBinary()
+}
+
+// GobDecode implements gob.GobDecoder using UnmarshalBinary.
+func (t *Table) GobDecode(data []byte) error {
+	return t.UnmarshalBinary(data)
+}
+
+// MarshalText implements encoding.TextMarshaler using the standard base64
+// encoding of MarshalBinary's output, so that a Table can be embedded in JSON
+// or YAML. The text is a third larger than the binary encoding.
+func (t *Table) MarshalText() ([]byte, error) {
+	data, err := t.MarshalBinary()
 	if err != nil {
+		return nil, err
+	}
+	text := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(text, data)
+	return text, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, decoding a Table
+// encoded by MarshalText as UnmarshalBinary would.
+func (t *Table) UnmarshalText(text []byte) error {
+	data := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+	n, err := base64.StdEncoding.Decode(data, text)
+	if err != nil {
+		t.Reset()
+		return fmt.Errorf("mph.UnmarshalText: %w", err)
+	}
+	return t.UnmarshalBinary(data[:n])
+}
+
+// Reset empties t, keeping the memory of its level arrays for reuse by a
+// later UnmarshalBinary or ReadFrom, which makes reloading a table of similar
+// size cheaper. The arrays of a table opened with OpenMmap belong to the
+// mapping and are not kept; it must still be closed, even once it has been
+// reloaded. Nor are the arrays of a table decoded by UnmarshalBinaryNoCopy,
+// which may belong to the caller.
+func (t *Table) Reset() {
+	if t.mapping != nil {
+		*t = Table{mapping: t.mapping}
+		return
+	}
+	if t.aliased {
+		*t = Table{}
+		return
+	}
+	*t = Table{level0: t.level0[:0], level0u16: t.level0u16[:0], level1: t.level1[:0]}
+}
+
+// unmarshal implements UnmarshalBinary. If alias is set, the level arrays and
+// stored keys of the decoded table may refer to data instead of copies of it.
+func (t *Table) unmarshal(data []byte, alias bool) error {
+	t.Reset()
+	if v, err := encodingVersion(data); err == nil && len(data) >= headerLen(v) && chunkedFlag(v, data) {
+		// The parts of a chunked encoding are only located by reading it,
+		// which also checks its header's checksum before parsing it.
+		n, err := t.ReadFrom(bytes.NewReader(data))
+		if err == nil && n != int64(len(data)) {
+			t.Reset()
+			err = fmt.Errorf("%w: %d bytes after the chunked table", ErrInvalidTable, int64(len(data))-n)
+		}
+		return err
+	}
+	h, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+	if h.flags&flagWideIndex != 0 {
+		return errors.New("mph.UnmarshalBinary: table has 64-bit indices; use Table64")
+	}
+	start := headerLen(h.version)
+	if len(data)-start < h.bloomLen {
+		return fmt.Errorf("%w for the bloom filter", ErrShortData)
+	}
+	off0, off1, end := h.offsets(bphw)
+	if h.flags&flagCompact == 0 && len(data) < end {
+		return fmt.Errorf("%w for the level arrays", ErrShortData)
+	}
+	u := Table{
+		level0:    t.level0,
+		level0u16: t.level0u16,
+		level0Len: h.level0Len,
+		level1:    t.level1,
+		level1Len: h.level1Len,
+		numKeys:   h.numKeys,
+		fallback:  h.flags&flagFallback != 0,
+		ids:       h.flags&flagIDs != 0,
+		wideHash:  h.flags&flagWideHash != 0,
+		mapping:   t.mapping,
+
+		loadFactor: h.loadFactor,
+		fpProb:     h.fpProb,
+		bucketSeed: h.bucketSeed,
+	}
+	if u.hasher, err = h.hasher(); err != nil {
+		return err
+	}
+	if u.reduction, err = h.reduction(); err != nil {
+		return err
+	}
+	if u.folding, err = h.folding(); err != nil {
 		return err
 	}
-	t.level0 = make([]uint32, t.level0Len)
-	start += bloomFilterLen
-	for i := 0; i < t.level0Len; i++ {
-		t.level0[i] = binary.LittleEndian.Uint32(data[start+i*bphw:])
+	if u.filter, err = unmarshalFilter(data[start : start+h.bloomLen]); err != nil {
+		return err
+	}
+	// Only one of the seed arrays is kept, so that lookups can tell which.
+	narrow := h.flags&flagNarrowSeeds != 0
+	if narrow {
+		u.level0 = nil
+	} else {
+		u.level0u16 = nil
+	}
+	if h.flags&flagCompact != 0 {
+		start += h.bloomLen
+		var n int
+		if narrow {
+			u.level0u16, n, err = decodeUvarints(u.level0u16, data[start:], u.level0Len)
+		} else {
+			u.level0, n, err = decodeUvarints(u.level0, data[start:], u.level0Len)
+		}
+		if err != nil {
+			return err
+		}
+		start += n
+		if u.level1, n, err = decodeUvarints(u.level1, data[start:], u.level1Len); err != nil {
+			return err
+		}
+		start += n
+	} else {
+		if narrow {
+			u.level0u16 = decodeUint16s(u.level0u16, data[off0:], u.level0Len, alias)
+		} else {
+			u.level0 = decodeUint32s(u.level0, data[off0:], u.level0Len, alias)
+		}
+		u.level1 = decodeUint32s(u.level1, data[off1:], u.level1Len, alias)
+		start = end
+	}
+	if u.fingerprintBits, err = h.fingerprintBits(); err != nil {
+		return err
 	}
-	t.level1 = make([]uint32, t.level1Len)
-	start += t.level0Len * bphw
-	for i := 0; i < t.level1Len; i++ {
-		t.level1[i] = binary.LittleEndian.Uint32(data[start+i*bphw:])
+	if u.fingerprintBits != 0 {
+		n := h.fingerprintsLen()
+		if len(data)-start < n {
+			return fmt.Errorf("%w for the fingerprints", ErrShortData)
+		}
+		if u.fingerprints = data[start : start+n]; !alias {
+			u.fingerprints = slices.Clone(u.fingerprints)
+		}
+		start += n
 	}
+	if h.version == 1 {
+		if u.numKeys = keyCountV1(u.level1); u.numKeys > u.level1Len {
+			return fmt.Errorf("%w: index %d in %d level1 slots", ErrInvalidTable, u.numKeys-1, u.level1Len)
+		}
+	}
+	if h.version < 6 {
+		u.loadFactor = estimateLoadFactor(u.numKeys, u.level1Len)
+	}
+	if h.flags&flagKeys != 0 {
+		n, err := u.unmarshalKeys(data[start:], h.flags&flagFrontCoded != 0, alias)
+		if err != nil {
+			return err
+		}
+		start += n
+	}
+	if h.version >= 4 {
+		if err := verifyChecksum(data, start); err != nil {
+			return err
+		}
+	}
+	*t = u
 	return nil
 }
+
+// nativeLittleEndian reports whether the host stores integers in
+// little-endian order, the order of the level arrays in the encoding.
+var nativeLittleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// decodeUint32s returns the n little-endian uint32s at the start of data,
+// decoded into dst if it is large enough. If alias is set and the host can
+// read them in place, the result refers to data's memory instead of a copy.
+// Otherwise a little-endian host copies them as bytes, whatever their
+// alignment, rather than decoding each in turn.
+func decodeUint32s(dst []uint32, data []byte, n int, alias bool) []uint32 {
+	if alias && n > 0 && nativeLittleEndian && uintptr(unsafe.Pointer(&data[0]))%bphw == 0 {
+		return unsafe.Slice((*uint32)(unsafe.Pointer(&data[0])), n)
+	}
+	vs := resize(dst, n)
+	if nativeLittleEndian && n > 0 {
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(&vs[0])), n*bphw), data[:n*bphw])
+		return vs
+	}
+	for i := range vs {
+		vs[i] = binary.LittleEndian.Uint32(data[i*bphw:])
+	}
+	return vs
+}
+
+// marshalFilter encodes f. A nil f, as in a table built WithoutBloom, is
+// encoded as nothing.
+func marshalFilter(f *bloom.Filter) ([]byte, error) {
+	if f == nil {
+		return nil, nil
+	}
+	return f.MarshalBinary()
+}
+
+// unmarshalFilter decodes a filter encoded by marshalFilter.
+func unmarshalFilter(data []byte) (*bloom.Filter, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	f := new(bloom.Filter)
+	if err := f.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// verifyChecksum checks the checksum which follows the first n bytes of data.
+func verifyChecksum(data []byte, n int) error {
+	if len(data) < n+checksumLen {
+		return fmt.Errorf("%w for the checksum", ErrShortData)
+	}
+	if crc32.ChecksumIEEE(data[:n]) != binary.LittleEndian.Uint32(data[n:]) {
+		return errChecksum
+	}
+	return nil
+}
+
+// estimateLoadFactor recovers the load factor of a table encoded before
+// version 6, which didn't record it, from its number of keys and slots.
+func estimateLoadFactor(numKeys, level1Len int) float32 {
+	if numKeys == 0 {
+		return 1.0
+	}
+	return float32(numKeys) / float32(level1Len)
+}
+
+// appendUvarints appends the uvarint encoding of each of vs to b.
+func appendUvarints[E uint16 | uint32](b []byte, vs []E) []byte {
+	for _, v := range vs {
+		b = binary.AppendUvarint(b, uint64(v))
+	}
+	return b
+}
+
+// decodeUvarints decodes n values encoded by appendUvarints from the start of
+// data, into dst if it is large enough, and returns them along with the
+// number of bytes they took. Values too large for E are rejected.
+func decodeUvarints[E uint16 | uint32](dst []E, data []byte, n int) ([]E, int, error) {
+	// Each value takes at least a byte.
+	if n > len(data) {
+		return nil, 0, fmt.Errorf("%w for the level arrays", ErrShortData)
+	}
+	vs := resize(dst, n)
+	var start int
+	for i := range vs {
+		v, w := binary.Uvarint(data[start:])
+		if w == 0 {
+			return nil, 0, fmt.Errorf("%w for the level arrays", ErrShortData)
+		}
+		if w < 0 || v > uint64(^E(0)) {
+			return nil, 0, errors.New("mph.UnmarshalBinary: bad compact level arrays")
+		}
+		vs[i] = E(v)
+		start += w
+	}
+	return vs, start, nil
+}
+
+// keyCountV1 recovers the number of keys of a version 1 table, which didn't
+// record it, from the largest index in its level1 array.
+func keyCountV1(level1 []uint32) int {
+	var n int
+	for _, v := range level1 {
+		if int(v) >= n {
+			n = int(v) + 1
+		}
+	}
+	return n
+}
+
+// unmarshalKeys decodes the stored keys at the start of data, which are
+// front-coded if frontCoded is set, and returns the length of their encoding.
+// If alias is set, the keys refer to data instead of a copy of it.
+func (t *Table) unmarshalKeys(data []byte, frontCoded, alias bool) (int, error) {
+	var (
+		start    int
+		prefixes []int
+	)
+	if frontCoded {
+		if t.numKeys > len(data) {
+			return 0, fmt.Errorf("%w for the stored keys", ErrShortData)
+		}
+		prefixes = make([]int, t.numKeys)
+		for i := range prefixes {
+			p, w := binary.Uvarint(data[start:])
+			if w <= 0 || p > uint64(len(data)) {
+				return 0, errors.New("mph.UnmarshalBinary: bad key prefix lengths")
+			}
+			start += w
+			prefixes[i] = int(p)
+		}
+	}
+	if t.numKeys > len(data)-start {
+		return 0, fmt.Errorf("%w for the stored keys", ErrShortData)
+	}
+	keyEnds := make([]int, t.numKeys)
+	var end int
+	for i := range keyEnds {
+		n, w := binary.Uvarint(data[start:])
+		if w <= 0 || n > uint64(len(data)) {
+			return 0, errors.New("mph.UnmarshalBinary: bad key lengths")
+		}
+		start += w
+		end += int(n)
+		keyEnds[i] = end
+	}
+	if len(data)-start < end {
+		return 0, fmt.Errorf("%w for the stored keys", ErrShortData)
+	}
+	if prefixes != nil {
+		if err := checkPrefixes(prefixes, keyEnds); err != nil {
+			return 0, err
+		}
+	}
+	if alias {
+		t.keyData = unsafeString(data[start : start+end])
+	} else {
+		t.keyData = string(data[start : start+end])
+	}
+	t.keyEnds = keyEnds
+	t.keyPrefixes = prefixes
+	return start + end, nil
+}
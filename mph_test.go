@@ -2,16 +2,113 @@ package mph
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strconv"
 	"sync"
 	"testing"
+	"unsafe"
 )
 
 func TestBuild_simple(t *testing.T) {
 	testTable(t, []string{"foo", "foo2", "bar", "baz"}, []string{"quux"})
 }
 
+func TestBuild_tiny(t *testing.T) {
+	keys := []string{"foo", "bar", "baz"}
+	for n := 0; n <= len(keys); n++ {
+		testTable(t, keys[:n], keys[n:])
+	}
+}
+
+func TestBuild_empty(t *testing.T) {
+	for _, keys := range [][]string{nil, {}} {
+		table, err := Build(keys, 1.0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Without a filter, there is still no key's index to report.
+		noBloom, err := NewBuilder(WithoutBloom()).Build(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := noBloom.Lookup("foo"); ok {
+			t.Error("WithoutBloom: Lookup(foo): got ok; want !ok")
+		}
+		if ns, oks := noBloom.LookupAll([]string{"foo"}); ns[0] != 0 || oks[0] {
+			t.Errorf("WithoutBloom: LookupAll(foo): got (%d, %t); want (0, false)", ns[0], oks[0])
+		}
+		if noBloom.Contains("foo") {
+			t.Error("WithoutBloom: Contains(foo): got true; want false")
+		}
+		table64, err := NewBuilder(WithoutBloom()).Build64(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := table64.Lookup("foo"); ok {
+			t.Error("WithoutBloom: Table64.Lookup(foo): got ok; want !ok")
+		}
+		data, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Table
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		for _, tbl := range []*Table{table, &decoded} {
+			if got := tbl.Len(); got != 0 {
+				t.Errorf("Len: got %d; want 0", got)
+			}
+			for _, key := range []string{"", "foo", "bar"} {
+				if _, ok := tbl.Lookup(key); ok {
+					t.Errorf("Lookup(%q): got ok; want !ok", key)
+				}
+			}
+		}
+	}
+}
+
+func TestBuild_duplicateKeys(t *testing.T) {
+	var keys []string
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	for _, tt := range []struct {
+		keys  []string
+		key   string
+		index int
+	}{
+		{[]string{"a", "a"}, "a", 1},
+		{[]string{"a", "b", "c", "b", "a"}, "b", 3},
+		{append(keys, "500", "17"), "500", 1000},
+		{append(append([]string{}, keys...), keys...), "0", 1000},
+	} {
+		_, err := Build(tt.keys, 1.0, 0.01)
+		dupErr, ok := err.(*DuplicateKeyError)
+		if !ok {
+			t.Errorf("Build: got err=%v; want *DuplicateKeyError", err)
+			continue
+		}
+		if dupErr.Key != tt.key || dupErr.Index != tt.index {
+			t.Errorf("Build: got duplicate %q at %d; want %q at %d",
+				dupErr.Key, dupErr.Index, tt.key, tt.index)
+		}
+	}
+}
+
 func TestBuild_stress(t *testing.T) {
 	var keys, extra []string
 	for i := 0; i < 20000; i++ {
@@ -22,26 +119,911 @@ func TestBuild_stress(t *testing.T) {
 			extra = append(extra, s)
 		}
 	}
-	testTable(t, keys, extra)
-}
+	testTable(t, keys, extra)
+}
+
+func testTable(t *testing.T, keys []string, extra []string) {
+	table, err := Build(keys, 1.0, 1e-9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		n, ok := table.Lookup(key)
+		if !ok {
+			t.Errorf("Lookup(%s): got !ok; want ok", key)
+			continue
+		}
+		if int(n) != i {
+			t.Errorf("Lookup(%s): got n=%d; want %d", key, n, i)
+		}
+	}
+	for _, key := range extra {
+		if _, ok := table.Lookup(key); ok {
+			t.Errorf("Lookup(%s): got ok; want !ok", key)
+		}
+	}
+}
+
+func TestBuild_deterministic(t *testing.T) {
+	var keys []string
+	for i := 0; i < 10000; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	var prev []byte
+	for i := 0; i < 3; i++ {
+		table, err := Build(keys, 1.0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prev != nil && !bytes.Equal(data, prev) {
+			t.Fatal("building the same keys twice gave different tables")
+		}
+		prev = data
+	}
+}
+
+func TestPlaceKeys_allocs(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b := NewBuilder()
+	level0Len, _ := tableSizes(len(keys), 1.0, defaultLevel0Ratio)
+	// Apart from the level arrays, placeKeys should only allocate when the
+	// pooled scratch buffers need to grow, rather than anything per bucket
+	// or per seed.
+	if allocs := testing.AllocsPerRun(5, func() { placeKeys[uint32](context.Background(), b, keys, 1.0, false) }); allocs > 50 {
+		t.Errorf("placeKeys: got %.0f allocations for %d buckets; want at most 50",
+			allocs, level0Len)
+	}
+}
+
+func TestTableSizes(t *testing.T) {
+	for _, tt := range []struct {
+		numKeys              int
+		loadFactor           float32
+		level0Len, level1Len int
+	}{
+		{0, 1.0, 1, 1},
+		{3, 1.0, 1, 3},
+		{1000, 0.5, 500, 2000},
+		// float32 rounds 2^24+1 down to 2^24, which would leave a key
+		// without a slot.
+		{1<<24 + 1, 1.0, 1 << 22, 1<<24 + 1},
+		{1<<25 + 3, 0.5, 1<<24 + 1, 1<<26 + 6},
+	} {
+		level0Len, level1Len := tableSizes(tt.numKeys, tt.loadFactor, defaultLevel0Ratio)
+		if level0Len != tt.level0Len || level1Len != tt.level1Len {
+			t.Errorf("tableSizes(%d, %v): got (%d, %d); want (%d, %d)",
+				tt.numKeys, tt.loadFactor, level0Len, level1Len, tt.level0Len, tt.level1Len)
+		}
+	}
+}
+
+func TestLookupBytes(t *testing.T) {
+	var keys, extra []string
+	for i := 0; i < 2000; i++ {
+		s := strconv.Itoa(i)
+		if i < 1000 {
+			keys = append(keys, s)
+		} else {
+			extra = append(extra, s)
+		}
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range append(keys, extra...) {
+		n0, ok0 := table.Lookup(key)
+		n1, ok1 := table.LookupBytes([]byte(key))
+		if n0 != n1 || ok0 != ok1 {
+			t.Errorf("LookupBytes(%s): got (%d, %t); want (%d, %t)", key, n1, ok1, n0, ok0)
+		}
+	}
+	b := []byte(keys[len(keys)/2])
+	if allocs := testing.AllocsPerRun(100, func() { table.LookupBytes(b) }); allocs != 0 {
+		t.Errorf("LookupBytes: got %.1f allocs; want 0", allocs)
+	}
+}
+
+func TestBuildBytes(t *testing.T) {
+	var keys []string
+	var byteKeys [][]byte
+	for i := 0; i < 1000; i++ {
+		s := strconv.Itoa(i)
+		keys = append(keys, s)
+		byteKeys = append(byteKeys, []byte(s))
+	}
+	want, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := BuildBytes(byteKeys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantData, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotData, err := got.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotData, wantData) {
+		t.Error("BuildBytes and Build produced different tables")
+	}
+	for i, key := range keys {
+		n0, ok0 := got.Lookup(key)
+		n1, ok1 := got.LookupBytes(byteKeys[i])
+		if !ok0 || !ok1 || n0 != uint32(i) || n1 != uint32(i) {
+			t.Errorf("Lookup(%s): got (%d, %t), LookupBytes: (%d, %t); want %d",
+				key, n0, ok0, n1, ok1, i)
+		}
+	}
+
+	// The key of an error outlives the caller's bytes.
+	dupKeys := [][]byte{[]byte("foo"), []byte("bar"), []byte("foo")}
+	_, err = BuildBytes(dupKeys, 1.0, 0.01)
+	var dup *DuplicateKeyError
+	if !errors.As(err, &dup) {
+		t.Fatalf("BuildBytes of duplicate keys: got err=%v; want a *DuplicateKeyError", err)
+	}
+	copy(dupKeys[0], "xxx")
+	copy(dupKeys[2], "xxx")
+	if dup.Key != "foo" {
+		t.Errorf("DuplicateKeyError.Key after the keys changed: got %q; want %q", dup.Key, "foo")
+	}
+}
+
+func TestLen(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := table.Len(); got != len(keys) {
+		t.Errorf("Len: got %d; want %d", got, len(keys))
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.Len(); got != len(keys) {
+		t.Errorf("Len after round trip: got %d; want %d", got, len(keys))
+	}
+}
+
+func TestContains(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if !table.Contains(key) {
+			t.Errorf("Contains(%s): got false; want true", key)
+		}
+	}
+	// With a false positive rate of 1%, some absent keys may be reported
+	// as present, but not many.
+	var falsePositives int
+	for i := len(keys); i < 2*len(keys); i++ {
+		key := strconv.Itoa(i)
+		got := table.Contains(key)
+		if _, ok := table.Lookup(key); ok != got {
+			t.Errorf("Contains(%s): got %t; want %t as reported by Lookup", key, got, ok)
+		}
+		if got {
+			falsePositives++
+		}
+	}
+	if falsePositives > len(keys)/20 {
+		t.Errorf("Contains: got %d false positives among %d absent keys; want at most %d",
+			falsePositives, len(keys), len(keys)/20)
+	}
+
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var zero, failed Table
+	if err := failed.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("UnmarshalBinary of truncated data: got nil error")
+	}
+	for name, tbl := range map[string]*Table{"nil": nil, "zero": &zero, "failed decode": &failed} {
+		if tbl.Contains(keys[0]) {
+			t.Errorf("%s table: Contains(%s): got true; want false", name, keys[0])
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	table, err := Build(keys, 1.0, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := table.Filter()
+	if f == nil || f != table.filter {
+		t.Fatalf("Filter: got %p; want the table's filter %p", f, table.filter)
+	}
+	for _, key := range append(keys, "absent", "quuux") {
+		_, ok := table.Lookup(key)
+		if got := f.Has(key); got != ok {
+			t.Errorf("Filter().Has(%s): got %t; want %t as reported by Lookup", key, got, ok)
+		}
+	}
+	if got := table.FalsePositiveRate(); got != 0.001 {
+		t.Errorf("FalsePositiveRate: got %v; want 0.001", got)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.FalsePositiveRate(); got != 0.001 {
+		t.Errorf("FalsePositiveRate after UnmarshalBinary: got %v; want 0.001", got)
+	}
+	allowLegacyFormat(t)
+	if err := decoded.UnmarshalBinary(marshalV1(t, table)); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.FalsePositiveRate(); got != 0 {
+		t.Errorf("FalsePositiveRate of version 1 table: got %v; want 0", got)
+	}
+
+	table, err = NewBuilder(WithoutBloom()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := table.Filter(); f != nil {
+		t.Errorf("Filter of table built WithoutBloom: got %p; want nil", f)
+	}
+	if got := table.FalsePositiveRate(); got != 1 {
+		t.Errorf("FalsePositiveRate of table built WithoutBloom: got %v; want 1", got)
+	}
+}
+
+func TestLoadFactor(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := table.LoadFactor(); got != 1.0 {
+		t.Errorf("LoadFactor: got %v; want 1", got)
+	}
+	// Few seed attempts force the build to lower the load factor.
+	table, err = NewBuilder(WithMaxSeedAttempts(4)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lf := table.LoadFactor()
+	if lf >= 1.0 || table.level1Len != int(float32(len(keys))/lf) {
+		t.Errorf("LoadFactor: got %v with %d slots; want a reduced load factor which gives them",
+			lf, table.level1Len)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.LoadFactor(); got != lf {
+		t.Errorf("LoadFactor after UnmarshalBinary: got %v; want %v", got, lf)
+	}
+	if _, err := decoded.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.LoadFactor(); got != lf {
+		t.Errorf("LoadFactor after ReadFrom: got %v; want %v", got, lf)
+	}
+	allowLegacyFormat(t)
+	if err := decoded.UnmarshalBinary(marshalV1(t, table)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.LoadFactor(), float32(len(keys))/float32(table.level1Len); got != want {
+		t.Errorf("LoadFactor of version 1 table: got %v; want %v", got, want)
+	}
+}
+
+func TestUnmarshalBinary_v1(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	table, err := Build(keys, 1.0, 1e-9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(marshalV1(t, table)); !errors.Is(err, ErrNotTable) {
+		t.Fatalf("UnmarshalBinary without AllowLegacyFormat: got err=%v; want one wrapping %v", err, ErrNotTable)
+	}
+	allowLegacyFormat(t)
+	if err := decoded.UnmarshalBinary(marshalV1(t, table)); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.Len(); got != len(keys) {
+		t.Errorf("Len: got %d; want %d", got, len(keys))
+	}
+	for i, key := range keys {
+		n, ok := decoded.Lookup(key)
+		if !ok || int(n) != i {
+			t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+}
+
+func TestBuildWithKeys(t *testing.T) {
+	keys := []string{"foo", "", "bar", "baz", "quux"}
+	table, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range []*Table{table, &decoded} {
+		for i, key := range keys {
+			got, ok := tbl.Key(uint32(i))
+			if !ok || got != key {
+				t.Errorf("Key(%d): got (%q, %t); want (%q, true)", i, got, ok, key)
+			}
+		}
+		if _, ok := tbl.Key(uint32(len(keys))); ok {
+			t.Errorf("Key(%d): got ok; want !ok", len(keys))
+		}
+		if got := tbl.Keys(); !reflect.DeepEqual(got, keys) {
+			t.Errorf("Keys: got %q; want %q", got, keys)
+		}
+	}
+
+	table, err = Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := table.Key(0); ok {
+		t.Error("Key(0) without stored keys: got ok; want !ok")
+	}
+	if got := table.Keys(); got != nil {
+		t.Errorf("Keys without stored keys: got %q; want nil", got)
+	}
+}
+
+func TestLookupAll(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := make([]string, 2*len(keys))
+	for i := range queries {
+		queries[i] = strconv.Itoa(i)
+	}
+	ns, oks := table.LookupAll(queries)
+	if len(ns) != len(queries) || len(oks) != len(queries) {
+		t.Fatalf("LookupAll: got %d indices and %d results; want %d of each",
+			len(ns), len(oks), len(queries))
+	}
+	for i, key := range queries {
+		if n, ok := table.Lookup(key); ns[i] != n || oks[i] != ok {
+			t.Errorf("LookupAll: got (%d, %t) for %s; want (%d, %t) as reported by Lookup",
+				ns[i], oks[i], key, n, ok)
+		}
+	}
+}
+
+// TestLookup_concurrent looks up keys in shared tables from many goroutines,
+// which is meant to be run with -race to check that lookups only read them.
+func TestLookup_concurrent(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table64, err := Build64(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2*len(keys); i++ {
+				j := (i + g*len(keys)/4) % (2 * len(keys))
+				key := strconv.Itoa(j)
+				n, ok := table.Lookup(key)
+				if n1, ok1 := table.LookupBytes([]byte(key)); n1 != n || ok1 != ok {
+					t.Errorf("LookupBytes(%s): got (%d, %t); want (%d, %t)", key, n1, ok1, n, ok)
+				}
+				if table.Contains(key) != ok {
+					t.Errorf("Contains(%s): got %t; want %t", key, !ok, ok)
+				}
+				_, exact := table.LookupExact(key)
+				if j >= len(keys) {
+					if exact {
+						t.Errorf("LookupExact(%s): got found for an absent key", key)
+					}
+					continue
+				}
+				if !ok || !exact || n != uint32(j) {
+					t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, j)
+				}
+				if n64, ok64 := table64.Lookup(key); !ok64 || n64 != uint64(j) {
+					t.Errorf("Table64.Lookup(%s): got (%d, %t); want (%d, true)", key, n64, ok64, j)
+				}
+				if got, _ := table.Key(n); got != key {
+					t.Errorf("Key(%d): got %q; want %q", n, got, key)
+				}
+			}
+			ns, _ := table.LookupAll(keys)
+			for i, n := range ns {
+				if n != uint32(i) {
+					t.Errorf("LookupAll: got %d for %s; want %d", n, keys[i], i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBuildMarshal(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	data, err := BuildMarshal(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Error("BuildMarshal and MarshalBinary of Build differ")
+	}
+	if _, err := BuildMarshal([]string{"foo", "foo"}, 1.0, 0.01); err == nil {
+		t.Error("BuildMarshal of repeated keys: got nil error")
+	}
+}
+
+func TestLookupDefault(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const def = math.MaxUint32
+	for i, key := range keys {
+		if got := table.LookupDefault(key, def); got != uint32(i) {
+			t.Errorf("LookupDefault(%s): got %d; want %d", key, got, i)
+		}
+	}
+	var misses int
+	for i := len(keys); i < 2*len(keys); i++ {
+		key := strconv.Itoa(i)
+		want := uint32(def)
+		if n, ok := table.Lookup(key); ok {
+			want = n
+		}
+		got := table.LookupDefault(key, def)
+		if got != want {
+			t.Errorf("LookupDefault(%s): got %d; want %d", key, got, want)
+		}
+		if got == def {
+			misses++
+		}
+	}
+	// Only the bloom filter's false positives are not misses.
+	if misses < len(keys)*19/20 {
+		t.Errorf("LookupDefault: got %d misses among %d absent keys; want at least %d",
+			misses, len(keys), len(keys)*19/20)
+	}
+}
+
+func TestLookupIndex(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, opts := range [][]Option{nil, {WithFingerprint(8)}, {WithCaseFold()}} {
+		table, err := NewBuilder(opts...).Build(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			n, ok := table.Lookup(key)
+			if got := table.LookupIndex(key); !ok || got != n {
+				t.Fatalf("LookupIndex(%s): got %d; want %d, as from Lookup", key, got, n)
+			}
+		}
+	}
+	var zero Table
+	if got := zero.LookupIndex("a"); got != 0 {
+		t.Errorf("LookupIndex on a zero Table: got %d; want 0", got)
+	}
+}
+
+func TestLookup_emptyTable(t *testing.T) {
+	table, err := BuildWithKeys([]string{"a", "b"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var zero, failed, reset Table
+	if err := failed.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("UnmarshalBinary of truncated data: got nil error")
+	}
+	if err := reset.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	reset.Reset()
+	for name, tbl := range map[string]*Table{"nil": nil, "zero": &zero, "failed decode": &failed, "reset": &reset} {
+		if n, ok := tbl.Lookup("a"); n != 0 || ok {
+			t.Errorf("%s table: Lookup(a): got (%d, %t); want (0, false)", name, n, ok)
+		}
+		if n, ok := tbl.LookupHashed(table.Hash("a"), "a"); n != 0 || ok {
+			t.Errorf("%s table: LookupHashed(a): got (%d, %t); want (0, false)", name, n, ok)
+		}
+		if got := tbl.LookupDefault("a", 7); got != 7 {
+			t.Errorf("%s table: LookupDefault(a, 7): got %d", name, got)
+		}
+		if n, ok := tbl.LookupExact("a"); n != 0 || ok {
+			t.Errorf("%s table: LookupExact(a): got (%d, %t); want (0, false)", name, n, ok)
+		}
+		if n, ok := tbl.LookupBytes([]byte("a")); n != 0 || ok {
+			t.Errorf("%s table: LookupBytes(a): got (%d, %t); want (0, false)", name, n, ok)
+		}
+		if n := tbl.LookupIndex("a"); n != 0 {
+			t.Errorf("%s table: LookupIndex(a): got %d; want 0", name, n)
+		}
+		if tbl.Contains("a") {
+			t.Errorf("%s table: Contains(a): got true; want false", name)
+		}
+		ns, oks := tbl.LookupAll([]string{"a", "b", "c"})
+		if !slices.Equal(ns, []uint32{0, 0, 0}) || !slices.Equal(oks, []bool{false, false, false}) {
+			t.Errorf("%s table: LookupAll(a, b, c): got (%v, %v); want ([0 0 0], [false false false])", name, ns, oks)
+		}
+		ns, oks = tbl.LookupAllParallel([]string{"a", "b", "c"}, 2)
+		if !slices.Equal(ns, []uint32{0, 0, 0}) || !slices.Equal(oks, []bool{false, false, false}) {
+			t.Errorf("%s table: LookupAllParallel(a, b, c): got (%v, %v); want ([0 0 0], [false false false])", name, ns, oks)
+		}
+		if tableIdx, _, ok := LookupN("a", tbl); tableIdx != -1 || ok {
+			t.Errorf("%s table: LookupN(a): got (%d, %t); want (-1, false)", name, tableIdx, ok)
+		}
+	}
+	var zero64 Table64
+	for name, tbl := range map[string]*Table64{"nil": nil, "zero": &zero64} {
+		if n, ok := tbl.Lookup("a"); n != 0 || ok {
+			t.Errorf("%s Table64: Lookup(a): got (%d, %t); want (0, false)", name, n, ok)
+		}
+	}
+}
+
+func TestLookupN(t *testing.T) {
+	overlay, err := BuildWithKeys([]string{"foo", "bar"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := BuildWithKeys([]string{"baz", "quux", "foo"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		key      string
+		tableIdx int
+		n        uint32
+		ok       bool
+	}{
+		{"bar", 0, 1, true},
+		{"quux", 1, 1, true},
+		{"foo", 0, 0, true}, // the overlay wins
+		{"missing", -1, 0, false},
+	} {
+		if tableIdx, n, ok := LookupN(tt.key, overlay, base); tableIdx != tt.tableIdx || n != tt.n || ok != tt.ok {
+			t.Errorf("LookupN(%s): got (%d, %d, %t); want (%d, %d, %t)",
+				tt.key, tableIdx, n, ok, tt.tableIdx, tt.n, tt.ok)
+		}
+	}
+	if tableIdx, _, ok := LookupN("foo"); tableIdx != -1 || ok {
+		t.Errorf("LookupN without tables: got (%d, %t); want (-1, false)", tableIdx, ok)
+	}
+	// A nil table, such as an overlay not loaded yet, is skipped.
+	if tableIdx, n, ok := LookupN("quux", nil, base); tableIdx != 1 || n != 1 || !ok {
+		t.Errorf("LookupN(quux) with a nil overlay: got (%d, %d, %t); want (1, 1, true)", tableIdx, n, ok)
+	}
+	if tableIdx, _, ok := LookupN("quux", nil); tableIdx != -1 || ok {
+		t.Errorf("LookupN(quux) in a nil table: got (%d, %t); want (-1, false)", tableIdx, ok)
+	}
+}
+
+func TestLookupExact(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	// A false positive rate this high lets Lookup report many absent keys
+	// as found, which LookupExact must not.
+	table, err := BuildWithKeys(keys, 1.0, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		if n, ok := table.LookupExact(key); !ok || n != uint32(i) {
+			t.Errorf("LookupExact(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+	var falsePositives int
+	for i := len(keys); i < 2*len(keys); i++ {
+		key := strconv.Itoa(i)
+		if _, ok := table.Lookup(key); ok {
+			falsePositives++
+		}
+		if n, ok := table.LookupExact(key); ok {
+			t.Errorf("LookupExact(%s): got (%d, true); want ok=false", key, n)
+		}
+	}
+	if falsePositives == 0 {
+		t.Error("Lookup: got no false positives; the test needs some")
+	}
+}
+
+func TestUnmarshalBinary_checksum(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	for _, storeKeys := range []bool{false, true} {
+		table, err := Build(keys, 1.0, 0.01)
+		if storeKeys {
+			table, err = BuildWithKeys(keys, 1.0, 0.01)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bd, err := table.filter.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Corrupting the header, bloom filter, or key lengths may be
+		// detected before the checksum; the level arrays are covered
+		// only by the checksum.
+		levels := headerLen(ver) + len(bd)
+		levelsEnd := levels + (table.level0Len+table.level1Len)*bphw
+		for i := 1; i < len(data); i++ {
+			corrupt := bytes.Clone(data)
+			corrupt[i] ^= 0x10
+			var decoded Table
+			err := decoded.UnmarshalBinary(corrupt)
+			if err == nil {
+				t.Fatalf("UnmarshalBinary with byte %d flipped: got nil error", i)
+			}
+			if i >= levels && i < levelsEnd && err != errChecksum {
+				t.Errorf("UnmarshalBinary with byte %d flipped: got err=%v; want %v",
+					i, err, errChecksum)
+			}
+			if i < headerLen(ver) {
+				continue
+			}
+			_, err = decoded.ReadFrom(bytes.NewReader(corrupt))
+			if err == nil {
+				t.Fatalf("ReadFrom with byte %d flipped: got nil error", i)
+			}
+			if i >= levels && i < levelsEnd && err != errChecksum {
+				t.Errorf("ReadFrom with byte %d flipped: got err=%v; want %v",
+					i, err, errChecksum)
+			}
+		}
+	}
+}
+
+// marshalV1 encodes table in the original version 1 format, which lacked the
+// key count.
+func marshalV1(t *testing.T, table *Table) []byte {
+	bd, err := table.filter.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte{1}
+	data = binary.LittleEndian.AppendUint64(data, uint64(len(bd)))
+	data = binary.LittleEndian.AppendUint64(data, uint64(table.level0Len))
+	data = binary.LittleEndian.AppendUint64(data, uint64(table.level1Len))
+	data = append(data, bd...)
+	for _, v := range table.level0 {
+		data = binary.LittleEndian.AppendUint32(data, v)
+	}
+	for _, v := range table.level1 {
+		data = binary.LittleEndian.AppendUint32(data, v)
+	}
+	return data
+}
+
+// marshalV7 encodes table in version 7, which lacked the magic, and whose
+// header lacked the bucket seed.
+func marshalV7(t *testing.T, table *Table) []byte {
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := parseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := append([]byte{7}, data[wordOffset(ver, 0):wordOffset(ver, 7)]...)
+	old = append(old, data[headerLen(ver):headerLen(ver)+h.bloomLen]...)
+	old = append(old, make([]byte, padLen(7, len(old)))...)
+	// The level arrays start aligned in both versions, so the padding
+	// before level1 stays the same.
+	off0, _, _ := h.offsets(bphw)
+	old = append(old, data[off0:len(data)-checksumLen]...)
+	return binary.LittleEndian.AppendUint32(old, crc32.ChecksumIEEE(old))
+}
+
+// allowLegacyFormat sets AllowLegacyFormat for the rest of the test.
+func allowLegacyFormat(t *testing.T) {
+	AllowLegacyFormat = true
+	t.Cleanup(func() { AllowLegacyFormat = false })
+}
+
+func TestMagic(t *testing.T) {
+	keys := []string{"foo", "bar", "baz"}
+	table, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := table.MarshalCompact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := table.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	table64, err := Build64(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data64, err := table64.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		"MarshalBinary": data, "MarshalCompact": compact, "WriteTo": buf.Bytes(), "Table64.MarshalBinary": data64,
+	} {
+		if !bytes.HasPrefix(data, []byte(magic)) {
+			t.Errorf("%s: got prefix %q; want %q", name, data[:len(magic)], magic)
+		}
+	}
 
-func testTable(t *testing.T, keys []string, extra []string) {
-	table := Build(keys)
-	for i, key := range keys {
-		n, ok := table.Lookup(key)
-		if !ok {
-			t.Errorf("Lookup(%s): got !ok; want ok", key)
-			continue
+	for name, data := range map[string][]byte{
+		"a zip file":       []byte("PK\x03\x04\x14\x00\x00\x00\x08\x00"),
+		"JSON":             []byte(`{"keys": ["foo"]}`),
+		"a misspelt magic": append([]byte("MPH2"), data[len(magic):]...),
+		"version 0":        append([]byte{0}, data[1:]...),
+		"a single byte":    {0x8d},
+		"version 1 of mph": marshalV1(t, table),
+		"version 7 of mph": marshalV7(t, table),
+	} {
+		var decoded Table
+		if err := decoded.UnmarshalBinary(data); !errors.Is(err, ErrNotTable) {
+			t.Errorf("UnmarshalBinary of %s: got err=%v; want one wrapping %v", name, err, ErrNotTable)
 		}
-		if int(n) != i {
-			t.Errorf("Lookup(%s): got n=%d; want %d", n, i)
+		if err := decoded.UnmarshalBinaryNoCopy(data); !errors.Is(err, ErrNotTable) {
+			t.Errorf("UnmarshalBinaryNoCopy of %s: got err=%v; want one wrapping %v", name, err, ErrNotTable)
+		}
+		if _, err := decoded.ReadFrom(bytes.NewReader(data)); !errors.Is(err, ErrNotTable) {
+			t.Errorf("ReadFrom of %s: got err=%v; want one wrapping %v", name, err, ErrNotTable)
+		}
+		var decoded64 Table64
+		if err := decoded64.UnmarshalBinary(data); !errors.Is(err, ErrNotTable) {
+			t.Errorf("Table64.UnmarshalBinary of %s: got err=%v; want one wrapping %v", name, err, ErrNotTable)
 		}
 	}
-	for _, key := range extra {
-		if _, ok := table.Lookup(key); ok {
-			t.Errorf("Lookup(%s): got ok; want !ok", key)
+
+	// The magic followed by an unknown version is a table, just not one
+	// which this package can decode.
+	for _, v := range []byte{0, ver + 1} {
+		var decoded Table
+		if err := decoded.UnmarshalBinary(append([]byte(magic), v)); err == nil || errors.Is(err, ErrNotTable) {
+			t.Errorf("UnmarshalBinary of version %d: got err=%v; want one not wrapping %v", v, err, ErrNotTable)
+		}
+	}
+
+	// With AllowLegacyFormat, the versions before the magic are decoded.
+	allowLegacyFormat(t)
+	for _, old := range [][]byte{marshalV1(t, table), marshalV7(t, table)} {
+		var decoded Table
+		if err := decoded.UnmarshalBinary(old); err != nil {
+			t.Fatal(err)
+		}
+		for i, key := range keys {
+			if n, ok := decoded.Lookup(key); !ok || n != uint32(i) {
+				t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+	}
+}
+
+func TestFormatVersionOf(t *testing.T) {
+	table, err := Build([]string{"foo", "bar", "baz"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := table.MarshalCompact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		data []byte
+		want byte
+	}{
+		{data, FormatVersion},
+		{compact, FormatVersion},
+		{marshalV1(t, table), 1},
+	} {
+		if got, err := FormatVersionOf(tt.data); err != nil || got != tt.want {
+			t.Errorf("FormatVersionOf: got (%d, %v); want (%d, nil)", got, err, tt.want)
 		}
 	}
+
+	if _, err := FormatVersionOf(nil); !errors.Is(err, ErrShortData) {
+		t.Errorf("FormatVersionOf(nil): got err=%v; want one wrapping %v", err, ErrShortData)
+	}
+	data[len(magic)] = FormatVersion + 1
+	if got, err := FormatVersionOf(data); err == nil || got != FormatVersion+1 {
+		t.Errorf("FormatVersionOf of a newer version: got (%d, %v); want (%d, non-nil)", got, err, FormatVersion+1)
+	}
 }
 
 var (
@@ -56,7 +1038,35 @@ func BenchmarkBuild(b *testing.B) {
 		b.Skip("unable to load dictionary file")
 	}
 	for i := 0; i < b.N; i++ {
-		Build(words)
+		Build(words, 1.0, 0.01)
+	}
+}
+
+// BenchmarkBuild_longKeys builds from long keys, for which the seed search,
+// which hashes each key of a bucket once per seed tried, dominates.
+func BenchmarkBuild_longKeys(b *testing.B) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%064d", i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Build(keys, 1.0, 0.01); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPlaceKeys(b *testing.B) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	builder := NewBuilder()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		placeKeys[uint32](context.Background(), builder, keys, 1.0, false)
 	}
 }
 
@@ -78,6 +1088,29 @@ func BenchmarkTable(b *testing.B) {
 	}
 }
 
+func BenchmarkTableBytes(b *testing.B) {
+	wordsOnce.Do(loadBenchTable)
+	if len(words) == 0 {
+		b.Skip("unable to load dictionary file")
+	}
+	keys := make([][]byte, len(words))
+	for i, word := range words {
+		keys[i] = []byte(word)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := i % len(keys)
+		n, ok := benchTable.LookupBytes(keys[j])
+		if !ok {
+			b.Fatal("missing key")
+		}
+		if n != uint32(j) {
+			b.Fatal("bad result index")
+		}
+	}
+}
+
 // For comparison against BenchmarkTable.
 func BenchmarkTableMap(b *testing.B) {
 	wordsOnce.Do(loadBenchTable)
@@ -101,6 +1134,51 @@ func BenchmarkTableMap(b *testing.B) {
 	}
 }
 
+var (
+	lookupAllOnce  sync.Once
+	lookupAllTable *Table
+	lookupAllKeys  []string
+)
+
+// loadLookupAll builds a table which is too large for the CPU caches and
+// picks 10k of its keys to look up.
+func loadLookupAll(b *testing.B) {
+	lookupAllOnce.Do(func() {
+		keys := make([]string, 1<<21)
+		for i := range keys {
+			keys[i] = strconv.Itoa(i)
+		}
+		var err error
+		if lookupAllTable, err = Build(keys, 1.0, 0.01); err != nil {
+			b.Fatal(err)
+		}
+		lookupAllKeys = make([]string, 10000)
+		for i := range lookupAllKeys {
+			lookupAllKeys[i] = keys[(i*7919)%len(keys)]
+		}
+	})
+}
+
+func BenchmarkLookupAll(b *testing.B) {
+	loadLookupAll(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lookupAllTable.LookupAll(lookupAllKeys)
+	}
+}
+
+func BenchmarkLookupAll_loop(b *testing.B) {
+	loadLookupAll(b)
+	ns := make([]uint32, len(lookupAllKeys))
+	oks := make([]bool, len(lookupAllKeys))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, key := range lookupAllKeys {
+			ns[j], oks[j] = lookupAllTable.Lookup(key)
+		}
+	}
+}
+
 func loadBenchTable() {
 	for _, dict := range []string{"/usr/share/dict/words", "/usr/dict/words"} {
 		var err error
@@ -110,7 +1188,7 @@ func loadBenchTable() {
 		}
 	}
 	if len(words) > 0 {
-		benchTable = Build(words)
+		benchTable, _ = Build(words, 1.0, 0.01)
 	}
 }
 
@@ -130,3 +1208,557 @@ func loadDict(dict string) ([]string, error) {
 	}
 	return words, nil
 }
+
+func TestAppendBinary(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	for _, storeKeys := range []bool{false, true} {
+		table, err := Build(keys, 1.0, 0.01)
+		if storeKeys {
+			table, err = BuildWithKeys(keys, 1.0, 0.01)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The spare capacity of the buffer holds garbage, which must not
+		// leak into the encoding.
+		prefix := []byte("prefix")
+		buf := bytes.Repeat([]byte{0xff}, len(prefix)+len(want)+64)
+		buf = append(buf[:0], prefix...)
+		got, err := table.AppendBinary(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, append(prefix, want...)) {
+			t.Errorf("AppendBinary(%q) with storeKeys=%t: got %x; want prefix followed by %x",
+				prefix, storeKeys, got, want)
+		}
+		var decoded Table
+		if err := decoded.UnmarshalBinary(got[len(prefix):]); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestMarshalText(t *testing.T) {
+	type config struct {
+		Name  string
+		Table *Table
+		Count int
+	}
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	table, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(config{"keys", table, len(keys)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(data) {
+		t.Fatalf("json.Marshal: got invalid JSON %s", data)
+	}
+	var got config
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "keys" || got.Count != len(keys) || got.Table == nil {
+		t.Fatalf("json.Unmarshal: got %+v", got)
+	}
+	if !got.Table.Equal(table) {
+		t.Error("json.Unmarshal: decoded table differs from the encoded one")
+	}
+	for i, key := range keys {
+		if n, ok := got.Table.Lookup(key); !ok || n != uint32(i) {
+			t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+
+	if err := got.Table.UnmarshalText([]byte("not base64!")); err == nil {
+		t.Error("UnmarshalText of invalid base64: got nil error")
+	} else if got.Table.Len() != 0 {
+		t.Errorf("UnmarshalText of invalid base64: got Len %d; want an empty table", got.Table.Len())
+	}
+}
+
+func TestGob(t *testing.T) {
+	type message struct {
+		Name  string
+		Table *Table
+		Count int
+	}
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	table, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(message{"keys", table, len(keys)}); err != nil {
+		t.Fatal(err)
+	}
+	var got message
+	if err := gob.NewDecoder(&buf).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "keys" || got.Count != len(keys) || got.Table == nil {
+		t.Fatalf("Decode: got %+v", got)
+	}
+	if !got.Table.Equal(table) {
+		t.Error("Decode: decoded table differs from the encoded one")
+	}
+	for i, key := range keys {
+		if n, ok := got.Table.Lookup(key); !ok || n != uint32(i) {
+			t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	for _, build := range []func([]string, float32, float64) (*Table, error){Build, BuildWithKeys} {
+		table, err := build(keys, 1.0, 0.01)
+		if err != nil {
+			f.Fatal(err)
+		}
+		data, err := table.MarshalBinary()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	table64, err := Build64(keys, 1.0, 0.01)
+	if err != nil {
+		f.Fatal(err)
+	}
+	data, err := table64.MarshalBinary()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var table Table
+		if err := table.UnmarshalBinary(data); err == nil {
+			for _, key := range keys {
+				if n, ok := table.LookupExact(key); ok {
+					table.Key(n)
+				}
+			}
+			table.Verify()
+			if _, err := table.MarshalBinary(); err != nil {
+				t.Errorf("MarshalBinary of a decoded table: %v", err)
+			}
+		}
+		var read Table
+		if _, err := read.ReadFrom(bytes.NewReader(data)); err == nil {
+			read.Lookup(keys[0])
+		}
+		var table64 Table64
+		if err := table64.UnmarshalBinary(data); err == nil {
+			table64.Lookup(keys[0])
+		}
+	})
+}
+
+func TestMarshalCompact(t *testing.T) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, storeKeys := range []bool{false, true} {
+		table, err := Build(keys, 0.9, 0.01)
+		if storeKeys {
+			table, err = BuildWithKeys(keys, 0.9, 0.01)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		fixed, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		compact, err := table.MarshalCompact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Seeds mostly take a byte and level1 entries three, rather than
+		// four bytes each.
+		fixedLevels := (table.level0Len + table.level1Len) * bphw
+		compactLevels := fixedLevels - (len(fixed) - len(compact))
+		t.Logf("storeKeys=%t: level arrays take %d bytes compact vs %d fixed; tables %d vs %d",
+			storeKeys, compactLevels, fixedLevels, len(compact), len(fixed))
+		if compactLevels > fixedLevels*4/5 {
+			t.Errorf("MarshalCompact: level arrays take %d bytes; want at most %d",
+				compactLevels, fixedLevels*4/5)
+		}
+
+		var decoded, read Table
+		if err := decoded.UnmarshalBinary(compact); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := read.ReadFrom(bytes.NewReader(compact)); err != nil {
+			t.Fatal(err)
+		}
+		for _, got := range []*Table{&decoded, &read} {
+			if !got.Equal(table) {
+				t.Fatal("decoded compact table differs from the encoded one")
+			}
+			// Reencoding yields the fixed-width encoding again.
+			data, err := got.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, fixed) {
+				t.Error("MarshalBinary of a decoded compact table differs from the original encoding")
+			}
+		}
+	}
+}
+
+func TestMarshalCompact_shortData(t *testing.T) {
+	table, err := BuildWithKeys([]string{"foo", "bar", "baz", "quux"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalCompact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < len(data); n++ {
+		var decoded Table
+		if err := decoded.UnmarshalBinary(data[:n]); !errors.Is(err, ErrShortData) {
+			t.Fatalf("UnmarshalBinary of %d of %d bytes: got err=%v; want one wrapping %v",
+				n, len(data), err, ErrShortData)
+		}
+		if _, err := decoded.ReadFrom(bytes.NewReader(data[:n])); err == nil {
+			t.Fatalf("ReadFrom of %d of %d bytes: got nil error", n, len(data))
+		}
+	}
+	var table64 Table64
+	if err := table64.UnmarshalBinary(data); err == nil {
+		t.Error("Table64.UnmarshalBinary of a compact table: got nil error")
+	}
+}
+
+func TestReset(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	var encodings [][]byte
+	for _, keys := range [][]string{keys, keys[:900]} {
+		table, err := Build(keys, 1.0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		encodings = append(encodings, data)
+	}
+
+	var table Table
+	if err := table.UnmarshalBinary(encodings[0]); err != nil {
+		t.Fatal(err)
+	}
+	level0, level1 := &table.level0[0], &table.level1[0]
+	table.Reset()
+	if table.Len() != 0 || len(table.level0) != 0 || len(table.level1) != 0 {
+		t.Fatalf("Reset: got %d keys and level arrays of %d and %d; want none",
+			table.Len(), len(table.level0), len(table.level1))
+	}
+	for _, decode := range []func([]byte) error{
+		table.UnmarshalBinary,
+		func(data []byte) error {
+			_, err := table.ReadFrom(bytes.NewReader(data))
+			return err
+		},
+	} {
+		if err := decode(encodings[1]); err != nil {
+			t.Fatal(err)
+		}
+		if &table.level0[0] != level0 || &table.level1[0] != level1 {
+			t.Error("decoding a smaller table did not reuse the level arrays")
+		}
+		if table.Len() != 900 {
+			t.Fatalf("Len: got %d; want 900", table.Len())
+		}
+		for i, key := range keys[:900] {
+			if n, ok := table.Lookup(key); !ok || n != uint32(i) {
+				t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+		if err := decode(encodings[1][:100]); err == nil {
+			t.Fatal("decoding a truncated table: got nil error")
+		}
+		if table.Len() != 0 || table.level0Len != 0 {
+			t.Errorf("decoding a truncated table: got a table of %d keys; want an empty one", table.Len())
+		}
+	}
+}
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden encodings in testdata")
+
+// TestMarshalBinary_golden checks that tables built from fixed keys encode
+// to the bytes in testdata, and that those still decode, so that neither a
+// change to the hashes or placement nor one to the encoding can go unnoticed.
+// A failure means that tables encoded by earlier releases would no longer be
+// found or read correctly: such a change needs a new FormatVersion, after
+// which the files may be rewritten with -update. The tables have no bloom
+// filter, whose encoding belongs to the bloom package.
+func TestMarshalBinary_golden(t *testing.T) {
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	plain, err := NewBuilder(WithoutBloom()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprinted, err := NewBuilder(WithFingerprint(8), WithNarrowSeeds()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprinted.setKeys(keys)
+	for _, tt := range []struct {
+		file    string
+		marshal func() ([]byte, error)
+	}{
+		{"plain.golden", plain.MarshalBinary},
+		{"compact.golden", plain.MarshalCompact},
+		{"fingerprint_keys.golden", fingerprinted.MarshalBinary},
+	} {
+		path := filepath.Join("testdata", tt.file)
+		data, err := tt.marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *updateGolden {
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		golden, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, golden) {
+			t.Errorf("%s: got a different encoding of %d bytes, want the %d in the file; "+
+				"the hashes or the format changed", path, len(data), len(golden))
+		}
+		var decoded Table
+		if err := decoded.UnmarshalBinary(golden); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		for i, key := range keys {
+			if n, ok := decoded.Lookup(key); !ok || n != uint32(i) {
+				t.Fatalf("%s: Lookup(%s): got (%d, %t); want (%d, true)", path, key, n, ok, i)
+			}
+		}
+	}
+}
+
+func TestUnmarshalBinaryNoCopy(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Backing the buffer by uint64s aligns it, and starting one byte in
+	// misaligns it.
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&make([]uint64, len(encoded)/8+1)[0])), len(encoded)+8)
+	within := func(p *uint32, data []byte) bool {
+		addr := uintptr(unsafe.Pointer(p))
+		start := uintptr(unsafe.Pointer(&data[0]))
+		return addr >= start && addr < start+uintptr(len(data))
+	}
+	for _, offset := range []int{0, 1} {
+		data := buf[offset : offset+len(encoded)]
+		copy(data, encoded)
+		var decoded, copied Table
+		if err := decoded.UnmarshalBinaryNoCopy(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := copied.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if within(&copied.level1[0], data) {
+			t.Errorf("offset %d: UnmarshalBinary refers to the data", offset)
+		}
+		if got, want := within(&decoded.level1[0], data), offset == 0 && nativeLittleEndian; got != want {
+			t.Errorf("offset %d: UnmarshalBinaryNoCopy refers to the data: got %t; want %t", offset, got, want)
+		}
+		for _, tbl := range []*Table{&decoded, &copied} {
+			if !tbl.Equal(table) {
+				t.Errorf("offset %d: decoded table differs from the encoded one", offset)
+			}
+			for i, key := range keys {
+				if n, ok := tbl.LookupExact(key); !ok || n != uint32(i) {
+					t.Fatalf("offset %d: LookupExact(%s): got (%d, %t); want (%d, true)", offset, key, n, ok, i)
+				}
+			}
+		}
+
+		// Decoding another table into decoded must not write to data.
+		other, err := Build(keys[:900], 1.0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherData, err := other.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := decoded.UnmarshalBinary(otherData); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, encoded) {
+			t.Errorf("offset %d: decoding into a table decoded without copying changed its data", offset)
+		}
+	}
+}
+
+func BenchmarkUnmarshalBinary_new(b *testing.B)    { benchmarkUnmarshalBinary(b, false) }
+func BenchmarkUnmarshalBinary_reuse(b *testing.B)  { benchmarkUnmarshalBinary(b, true) }
+func BenchmarkUnmarshalBinary_noCopy(b *testing.B) { benchmarkUnmarshalBinaryNoCopy(b) }
+
+func benchmarkUnmarshalBinaryNoCopy(b *testing.B) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := new(Table).UnmarshalBinaryNoCopy(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkUnmarshalBinary(b *testing.B, reuse bool) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !reuse {
+			table = new(Table)
+		}
+		if err := table.UnmarshalBinary(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestBuildWithIndices(t *testing.T) {
+	m := map[string]uint32{"foo": 7, "foo2": 1 << 31, "bar": 0, "baz": math.MaxUint32, "quux": 7}
+	table, err := BuildWithIndices(m, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Len() != len(m) {
+		t.Errorf("Len: got %d; want %d", table.Len(), len(m))
+	}
+	if err := table.Verify(); err != nil {
+		t.Error(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(table) {
+		t.Error("UnmarshalBinary: decoded table differs from the encoded one")
+	}
+	for key, want := range m {
+		for _, tbl := range []*Table{table, &decoded} {
+			if n, ok := tbl.Lookup(key); !ok || n != want {
+				t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, want)
+			}
+		}
+	}
+	again, err := BuildWithIndices(m, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Equal(table) {
+		t.Error("BuildWithIndices of the same map gave a different table")
+	}
+}
+
+func TestLookupHashed(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%064d", i)
+	}
+	for _, opts := range [][]Option{nil, {WithBucketSeed(7)}, {WithCaseFold()}, {WithFingerprint(16)}} {
+		table, err := NewBuilder(opts...).Build(keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range append(keys[:10:10], "missing", "MISSING") {
+			wantN, wantOK := table.Lookup(key)
+			if n, ok := table.LookupHashed(table.Hash(key), key); n != wantN || ok != wantOK {
+				t.Errorf("LookupHashed(%s): got (%d, %t); want (%d, %t)", key, n, ok, wantN, wantOK)
+			}
+		}
+	}
+}
+
+// BenchmarkLookupHashed looks up the same long key repeatedly, with and
+// without reusing its bucket hash.
+func BenchmarkLookupHashed(b *testing.B) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%064d", i)
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		b.Fatal(err)
+	}
+	key := keys[12345]
+	b.Run("Lookup", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			table.Lookup(key)
+		}
+	})
+	b.Run("LookupHashed", func(b *testing.B) {
+		h := table.Hash(key)
+		for i := 0; i < b.N; i++ {
+			table.LookupHashed(h, key)
+		}
+	})
+}
@@ -1,12 +1,11 @@
 package mph
 
-import (
-	"reflect"
-	"unsafe"
-)
-
 // This file contains an optimized murmur3 32-bit implementation tailored for
 // our specific use case. See https://en.wikipedia.org/wiki/MurmurHash.
+//
+// Murmur3 reads its input as little-endian blocks. The hashes, and so the
+// placement of keys in encoded tables, are the same on every platform, which
+// TestMurmur and TestBuild_golden check.
 
 // A murmurSeed is the initial state of a Murmur3 hash.
 type murmurSeed uint32
@@ -26,14 +25,8 @@ const (
 func (ms murmurSeed) hash(s string) uint32 {
 	h := uint32(ms)
 	l := len(s)
-	numBlocks := l / 4
-	header := reflect.SliceHeader{
-		Data: (*reflect.StringHeader)(unsafe.Pointer(&s)).Data,
-		Len:  numBlocks,
-		Cap:  numBlocks,
-	}
-	blocks := *(*[]uint32)(unsafe.Pointer(&header))
-	for _, k := range blocks {
+	for i := 0; i+4 <= l; i += 4 {
+		k := block(s[i : i+4])
 		k *= c1
 		k = (k << r1Left) | (k >> r1Right)
 		k *= c2
@@ -60,7 +53,19 @@ func (ms murmurSeed) hash(s string) uint32 {
 		h ^= k
 	}
 
-	h ^= uint32(l)
+	return fmix32(h ^ uint32(l))
+}
+
+// block returns the little-endian block of four bytes b. Compilers turn this
+// into a single load on little-endian platforms.
+func block(b string) uint32 {
+	_ = b[3]
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+// fmix32 is the finalizer of Murmur3, which makes each bit of h affect every
+// bit of the result.
+func fmix32(h uint32) uint32 {
 	h ^= h >> 16
 	h *= 0x85ebca6b
 	h ^= h >> 13
@@ -68,3 +73,54 @@ func (ms murmurSeed) hash(s string) uint32 {
 	h ^= h >> 16
 	return h
 }
+
+// premix appends to dst the part of the Murmur3 hash of s which doesn't depend
+// on the seed: each block of s multiplied and rotated into place followed, if
+// s has a partial final block, by the tail treated likewise. mixedHash
+// completes the hash for any seed.
+func premix(dst []uint32, s string) []uint32 {
+	l := len(s)
+	for i := 0; i+4 <= l; i += 4 {
+		k := block(s[i : i+4])
+		k *= c1
+		k = (k << r1Left) | (k >> r1Right)
+		k *= c2
+		dst = append(dst, k)
+	}
+
+	var k uint32
+	ntail := l & 3
+	itail := l - ntail
+	switch ntail {
+	case 3:
+		k ^= uint32(s[itail+2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(s[itail+1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(s[itail])
+		k *= c1
+		k = (k << r1Left) | (k >> r1Right)
+		k *= c2
+		dst = append(dst, k)
+	}
+	return dst
+}
+
+// mixedHash computes the 32-bit Murmur3 hash, using ms as the seed, of a
+// string of length l whose blocks were mixed by premix.
+func (ms murmurSeed) mixedHash(mixed []uint32, l int) uint32 {
+	h := uint32(ms)
+	numBlocks := l / 4
+	for _, k := range mixed[:numBlocks] {
+		h ^= k
+		h = (h << r2Left) | (h >> r2Right)
+		h = h*m + n
+	}
+	if l&3 != 0 {
+		h ^= mixed[numBlocks]
+	}
+
+	return fmix32(h ^ uint32(l))
+}
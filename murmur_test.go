@@ -1,6 +1,10 @@
 package mph
 
 import (
+	"encoding/binary"
+	"hash/crc32"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -40,6 +44,31 @@ func TestMurmur(t *testing.T) {
 	}
 }
 
+func TestMurmurMixed(t *testing.T) {
+	var mixed []uint32
+	for _, tt := range murmurTestCases {
+		mixed = premix(mixed[:0], tt.input)
+		got := tt.seed.mixedHash(mixed, len(tt.input))
+		if got != tt.want {
+			t.Errorf("mixedHash(premix(%q), seed=0x%x): got 0x%x; want %x",
+				tt.input, tt.seed, got, tt.want)
+		}
+	}
+}
+
+// TestMurmur_stable pins the hashes which place keys in tables built with
+// the default Hasher. Unlike the vectors above, which check Murmur3 itself,
+// it guards the commitment that these hashes never change: every encoded
+// table depends on them.
+func TestMurmur_stable(t *testing.T) {
+	if got, want := murmurSeed(0).hash("known"), uint32(0xda298dda); got != want {
+		t.Errorf("murmurSeed(0).hash(known): got %#08x; want %#08x", got, want)
+	}
+	if got, want := hash64(nil, 0, "known"), uint64(0xda298ddabd79c697); got != want {
+		t.Errorf("hash64(known): got %#016x; want %#016x", got, want)
+	}
+}
+
 func BenchmarkMurmur1(b *testing.B)   { benchmarkMurmur(b, 1) }
 func BenchmarkMurmur4(b *testing.B)   { benchmarkMurmur(b, 4) }
 func BenchmarkMurmur8(b *testing.B)   { benchmarkMurmur(b, 8) }
@@ -56,3 +85,29 @@ func benchmarkMurmur(b *testing.B, size int) {
 		seed.hash(s)
 	}
 }
+
+// TestBuild_golden checks that the placement of keys, which depends only on
+// their Murmur3 hashes, matches a table built on a little-endian platform, so
+// that encoded tables can be looked up on any platform.
+func TestBuild_golden(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	table, err := NewBuilder(WithoutBloom()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crc := crc32.NewIEEE()
+	for _, level := range [][]uint32{table.level0, table.level1} {
+		for _, v := range level {
+			binary.Write(crc, binary.LittleEndian, v)
+		}
+	}
+	if got, want := crc.Sum32(), uint32(0xd6b223c8); got != want {
+		t.Errorf("got level arrays with checksum %#08x; want %#08x", got, want)
+	}
+	if got, want := table.level0[:8], []uint32{29, 43, 18, 2, 10, 87, 72, 90}; !slices.Equal(got, want) {
+		t.Errorf("got level0 starting %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package mph
+
+import (
+	"encoding/binary"
+	"math"
+	"unsafe"
+)
+
+// narrowSeeds returns level0 as uint16s, or nil if some seed does not fit.
+func narrowSeeds(level0 []uint32) []uint16 {
+	for _, seed := range level0 {
+		if seed > math.MaxUint16 {
+			return nil
+		}
+	}
+	narrow := make([]uint16, len(level0))
+	for i, seed := range level0 {
+		narrow[i] = uint16(seed)
+	}
+	return narrow
+}
+
+// seed returns the seed of the i'th level0 bucket of t.
+func (t *Table) seed(i int) uint32 {
+	if t.level0u16 != nil {
+		return uint32(t.level0u16[i])
+	}
+	return t.level0[i]
+}
+
+// decodeUint16s is like decodeUint32s but for uint16s.
+func decodeUint16s(dst []uint16, data []byte, n int, alias bool) []uint16 {
+	if alias && n > 0 && nativeLittleEndian && uintptr(unsafe.Pointer(&data[0]))%bpqw == 0 {
+		return unsafe.Slice((*uint16)(unsafe.Pointer(&data[0])), n)
+	}
+	vs := resize(dst, n)
+	if nativeLittleEndian && n > 0 {
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(&vs[0])), n*bpqw), data[:n*bpqw])
+		return vs
+	}
+	for i := range vs {
+		vs[i] = binary.LittleEndian.Uint16(data[i*bpqw:])
+	}
+	return vs
+}
@@ -0,0 +1,161 @@
+package mph
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestWithNarrowSeeds(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	wide, err := NewBuilder().Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := NewBuilder(WithNarrowSeeds()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.level0 != nil || len(table.level0u16) != table.level0Len {
+		t.Fatalf("got %d 32-bit and %d 16-bit seeds; want 0 and %d",
+			len(table.level0), len(table.level0u16), table.level0Len)
+	}
+	for i, seed := range wide.level0 {
+		if seed != uint32(table.level0u16[i]) {
+			t.Fatalf("level0[%d]: got %d; want %d", i, table.level0u16[i], seed)
+		}
+	}
+	wideData, err := wide.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved := len(wideData) - len(data); saved < table.level0Len*bpqw-levelAlign {
+		t.Errorf("MarshalBinary: got %d bytes, %d fewer than with 32-bit seeds; want about %d fewer",
+			len(data), saved, table.level0Len*bpqw)
+	}
+	compact, err := table.MarshalCompact()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded, aliased, read, decodedCompact Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := aliased.unmarshal(data, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := decodedCompact.UnmarshalBinary(compact); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range []*Table{table, &decoded, &aliased, &read, &decodedCompact} {
+		if !tbl.Equal(table) {
+			t.Error("decoded table differs from the encoded one")
+		}
+		if err := tbl.Verify(); err != nil {
+			t.Error(err)
+		}
+		ns, _ := tbl.LookupAll(keys)
+		for i, key := range keys {
+			if n, ok := tbl.Lookup(key); !ok || n != uint32(i) {
+				t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+			if ns[i] != uint32(i) {
+				t.Fatalf("LookupAll: got %d for %s; want %d", ns[i], key, i)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := table.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Error("WriteTo and MarshalBinary differ")
+	}
+
+	// Decoding a table with 32-bit seeds into one with 16-bit seeds, and
+	// the reverse, leaves only the decoded seeds.
+	if err := decoded.UnmarshalBinary(wideData); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(wide) {
+		t.Error("UnmarshalBinary into a narrow table: got a different table")
+	}
+	if _, err := decoded.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(table) {
+		t.Error("ReadFrom into a wide table: got a different table")
+	}
+
+	var table64 Table64
+	if err := table64.UnmarshalBinary(data); err == nil {
+		t.Error("Table64.UnmarshalBinary of narrow seeds: got nil error")
+	}
+}
+
+func TestWithNarrowSeeds_wideSeed(t *testing.T) {
+	if got := narrowSeeds([]uint32{0, 7, math.MaxUint16}); len(got) != 3 || got[2] != math.MaxUint16 {
+		t.Errorf("narrowSeeds of seeds up to 65535: got %v", got)
+	}
+	if got := narrowSeeds([]uint32{0, 7, math.MaxUint16 + 1}); got != nil {
+		t.Errorf("narrowSeeds of seed 65536: got %v; want nil", got)
+	}
+
+	// Buckets placed by the fallback don't fit, so the seeds stay 32 bits.
+	keys := make([]string, 200)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := NewBuilder(WithNarrowSeeds(), WithHasher(clusteredHasher{}), WithLoadFactor(0.8),
+		WithMaxSeedAttempts(10000), WithProbingFallback()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.level0u16 != nil || len(table.level0) != table.level0Len {
+		t.Fatalf("got %d 32-bit and %d 16-bit seeds; want %d and 0",
+			len(table.level0), len(table.level0u16), table.level0Len)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range keys {
+		if n, ok := decoded.Lookup(key); !ok || n != uint32(i) {
+			t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+
+	// A compact encoding claiming 16-bit seeds may not hold larger ones.
+	compact, err := table.MarshalCompact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := parseHeader(compact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.flags |= flagNarrowSeeds
+	h.put(compact)
+	if err := decoded.UnmarshalBinary(compact); err == nil {
+		t.Error("UnmarshalBinary of 16-bit seeds above 65535: got nil error")
+	}
+	if _, err := decoded.ReadFrom(bytes.NewReader(compact)); err == nil {
+		t.Error("ReadFrom of 16-bit seeds above 65535: got nil error")
+	}
+}
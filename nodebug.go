@@ -0,0 +1,5 @@
+//go:build !mphdebug
+
+package mph
+
+const debugChecks = false
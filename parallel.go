@@ -0,0 +1,227 @@
+package mph
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/instabid/bloom"
+)
+
+// BuildParallel is like Build but searches for the seeds of several buckets
+// concurrently using the given number of worker goroutines. If workers is
+// less than 1, runtime.GOMAXPROCS(0) workers are used. The resulting table is
+// identical to the one produced by Build.
+func BuildParallel(keys []string, loadFactor float32, fpProb float64, workers int) (*Table, error) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if err := checkKeyCount(uint64(len(keys))); err != nil {
+		return nil, err
+	}
+	b := NewBuilder(WithLoadFactor(loadFactor), WithFalsePositiveRate(fpProb))
+	return build(context.Background(), b, keys, func(_ context.Context, keys []string, loadFactor float32, filter *bloom.Filter, spent uint64) (*Table, buildStats, error) {
+		t, stats := b.buildParallel(keys, loadFactor, filter, workers)
+		stats.totalSeedAttempts = spent + stats.seedAttempts
+		if t != nil {
+			t.build.totalSeedAttempts = stats.totalSeedAttempts
+		}
+		return t, stats, nil
+	})
+}
+
+// buildParallel is the concurrent counterpart of buildInternal. It returns
+// the table, or nil if the seed search is exhausted, and its buildStats.
+//
+// Workers claim buckets in the same largest-first order that buildInternal
+// uses and search for a seed speculatively, against whatever slots have been
+// claimed so far. Buckets are committed strictly in order: once all earlier
+// buckets are placed, the candidate seed is checked again and, if one of its
+// slots was claimed in the meantime, the search resumes from the next seed.
+// Since the set of claimed slots only grows, no seed skipped during the
+// speculative search could have become valid later, so each bucket ends up
+// with exactly the seed buildInternal would have chosen.
+func (b *Builder) buildParallel(keys []string, loadFactor float32, filter *bloom.Filter, workers int) (*Table, buildStats) {
+	s := getScratch()
+	defer putScratch(s)
+	level0Len, level1Len := b.reduction.sizes(len(keys), loadFactor, b.level0Ratio)
+	s.occ = resize(s.occ, level1Len)
+	clear(s.occ)
+	var (
+		level0  = make([]uint32, level0Len)
+		level1  = make([]uint32, level1Len)
+		buckets = b.bucketize(keys, level0Len, b.reduction, s)
+
+		mu        sync.RWMutex // guards occ, level0, level1, done, and failed
+		committed = sync.NewCond(&mu)
+		occ       = s.occ
+		done      int  // number of buckets committed
+		failed    bool // seed search was exhausted for some bucket
+		stuck     int  // the first bucket for which it was
+		next      atomic.Int64
+		wg        sync.WaitGroup
+	)
+
+	// search finds the first seed, starting at seed, for which bucket's keys
+	// map to distinct slots that are not yet occupied. The slots are
+	// returned in the order of bucket.vals. The bucket's keys must have been
+	// loaded into bh.
+	search := func(bh *bucketHasher, bucket indexBucket, seed uint32, slots []int) (uint32, []int, bool) {
+	trySeed:
+		for ; seed < b.maxSeedAttempts; seed++ {
+			slots = slots[:0]
+			for j := range bucket.vals {
+				n := bh.index(b.reduction, seed, j, level1Len)
+				for _, m := range slots {
+					if m == n {
+						continue trySeed
+					}
+				}
+				slots = append(slots, n)
+			}
+			mu.RLock()
+			stop, free := failed, true
+			for _, n := range slots {
+				if occ[n] {
+					free = false
+					break
+				}
+			}
+			mu.RUnlock()
+			if stop {
+				return 0, slots, false
+			}
+			if free {
+				return seed, slots, true
+			}
+		}
+		return 0, slots, false
+	}
+
+	// place finds a seed for the i'th bucket and commits it.
+	place := func(bh *bucketHasher, i int, slots []int) ([]int, bool) {
+		bucket := buckets[i]
+		var (
+			seed uint32
+			ok   bool
+		)
+		bh.reset(keys, bucket.vals)
+		for {
+			seed, slots, ok = search(bh, bucket, seed, slots)
+			mu.Lock()
+			if !ok {
+				if !failed {
+					stuck = i
+				}
+				failed = true
+				committed.Broadcast()
+				mu.Unlock()
+				return slots, false
+			}
+			for done != i && !failed {
+				committed.Wait()
+			}
+			if failed {
+				mu.Unlock()
+				return slots, false
+			}
+			free := true
+			for _, n := range slots {
+				if occ[n] {
+					free = false
+					break
+				}
+			}
+			if free {
+				for j, n := range slots {
+					occ[n] = true
+					level1[n] = uint32(bucket.vals[j])
+				}
+				level0[bucket.n] = seed
+				done++
+				committed.Broadcast()
+				mu.Unlock()
+				return slots, true
+			}
+			mu.Unlock()
+			seed++
+		}
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var (
+				bh    = bucketHasher{hasher: b.hasher, wide: b.wideHash()}
+				slots []int
+				ok    bool
+			)
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(buckets) {
+					return
+				}
+				if slots, ok = place(&bh, i, slots); !ok {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if failed {
+		// As for buildInternal, count the attempts for the buckets
+		// placed in order and the bucket whose search was exhausted.
+		stats := bucketStats(buckets[:done], level0, false, 0)
+		stats.seedAttempts += uint64(b.maxSeedAttempts)
+		stats.maxBucketSize = len(buckets[0].vals)
+		stats.placedBuckets, stats.numBuckets, stats.stuckBucketSize = done, len(buckets), len(buckets[stuck].vals)
+		return nil, stats
+	}
+
+	stats := bucketStats(buckets, level0, false, 0)
+	return &Table{
+		filter:    filter,
+		hasher:    b.hasher,
+		reduction: b.reduction,
+		level0:    level0,
+		level0Len: level0Len,
+		level1:    level1,
+		level1Len: level1Len,
+		numKeys:   len(keys),
+		build:     stats,
+		wideHash:  b.wideHash(),
+
+		loadFactor: loadFactor,
+		fpProb:     b.filterFPProb(),
+		bucketSeed: b.bucketSeed,
+	}, stats
+}
+
+// LookupAllParallel is like LookupAll but splits keys into contiguous chunks
+// which are looked up concurrently, one per worker goroutine. If workers is
+// less than 1, runtime.GOMAXPROCS(0) workers are used. Lookups don't modify
+// t, so this is safe, but it only pays off for batches large enough that
+// starting the goroutines costs little next to the lookups.
+func (t *Table) LookupAllParallel(keys []string, workers int) ([]uint32, []bool) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(keys))
+	ns := make([]uint32, len(keys))
+	oks := make([]bool, len(keys))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo, hi := w*len(keys)/workers, (w+1)*len(keys)/workers
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := lo; i < hi; i++ {
+				ns[i], oks[i] = t.Lookup(keys[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return ns, oks
+}
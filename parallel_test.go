@@ -0,0 +1,95 @@
+package mph
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestBuildParallel(t *testing.T) {
+	var keys []string
+	for i := 0; i < 10000; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	want, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantData, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{1, 2, 8} {
+		table, err := BuildParallel(keys, 1.0, 0.01, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, wantData) {
+			t.Errorf("BuildParallel with %d workers differs from Build", workers)
+		}
+		for i, key := range keys {
+			if n, ok := table.Lookup(key); !ok || int(n) != i {
+				t.Errorf("workers=%d: Lookup(%s): got (%d, %t); want (%d, true)",
+					workers, key, n, ok, i)
+			}
+		}
+	}
+
+	var dupErr *DuplicateKeyError
+	if _, err := BuildParallel(append(keys, keys[5]), 1.0, 0.01, 4); !errors.As(err, &dupErr) {
+		t.Errorf("BuildParallel with a duplicate key: got err=%v; want *DuplicateKeyError", err)
+	}
+}
+
+func BenchmarkBuildParallel(b *testing.B) {
+	wordsOnce.Do(loadBenchTable)
+	if len(words) == 0 {
+		b.Skip("unable to load dictionary file")
+	}
+	for i := 0; i < b.N; i++ {
+		BuildParallel(words, 1.0, 0.01, 0)
+	}
+}
+
+func TestLookupAllParallel(t *testing.T) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Half of the strings looked up are not keys.
+	lookups := make([]string, 2*len(keys))
+	for i := range lookups {
+		lookups[i] = strconv.Itoa(i)
+	}
+	wantNs, wantOks := table.LookupAll(lookups)
+	for _, workers := range []int{0, 1, 3, 8, len(lookups) + 1} {
+		ns, oks := table.LookupAllParallel(lookups, workers)
+		if !slices.Equal(ns, wantNs) || !slices.Equal(oks, wantOks) {
+			t.Errorf("LookupAllParallel with %d workers differs from LookupAll", workers)
+		}
+	}
+	if ns, oks := table.LookupAllParallel(nil, 4); len(ns) != 0 || len(oks) != 0 {
+		t.Errorf("LookupAllParallel(nil): got %v, %v; want empty results", ns, oks)
+	}
+}
+
+func BenchmarkLookupAllParallel(b *testing.B) {
+	loadLookupAll(b)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run("workers="+strconv.Itoa(workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				lookupAllTable.LookupAllParallel(lookupAllKeys, workers)
+			}
+		})
+	}
+}
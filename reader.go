@@ -0,0 +1,83 @@
+package mph
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BuildFromReader is like Build but reads the keys from r, one per line.
+// Lines are split as by bufio.ScanLines. Repeated keys are ignored, so each
+// key's index is the number of distinct keys which precede its first
+// occurrence.
+func BuildFromReader(r io.Reader, loadFactor float32, fpProb float64) (*Table, error) {
+	return NewBuilder(WithLoadFactor(loadFactor), WithFalsePositiveRate(fpProb)).BuildFromReader(r)
+}
+
+// BuildFromReader builds a Table from the keys read from r as described by
+// the package-level BuildFromReader.
+func (b *Builder) BuildFromReader(r io.Reader) (*Table, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, min(4096, b.maxLineLen)), b.maxLineLen)
+	var (
+		keys  = newDistinctKeys(b.expectedKeys)
+		lines int
+	)
+	for scanner.Scan() {
+		lines++
+		keys.add(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = fmt.Errorf("mph: line %d is longer than %d bytes: %w",
+				lines+1, b.maxLineLen, err)
+		}
+		return nil, err
+	}
+	return b.Build(keys.keys)
+}
+
+// BuildFromChan is like Build but receives the keys from ch until it is
+// closed. Like BuildFromReader, it ignores repeated keys, so each key's index
+// is the number of distinct keys received before its first occurrence, and
+// only one copy of each key is kept while receiving.
+func BuildFromChan(ch <-chan string, loadFactor float32, fpProb float64) (*Table, error) {
+	return NewBuilder(WithLoadFactor(loadFactor), WithFalsePositiveRate(fpProb)).BuildFromChan(ch)
+}
+
+// BuildFromChan builds a Table from the keys received from ch as described
+// by the package-level BuildFromChan.
+func (b *Builder) BuildFromChan(ch <-chan string) (*Table, error) {
+	keys := newDistinctKeys(b.expectedKeys)
+	for key := range ch {
+		keys.add(key)
+	}
+	return b.Build(keys.keys)
+}
+
+// A distinctKeys collects keys in the order of their first occurrence.
+type distinctKeys struct {
+	keys []string
+	seen map[string]struct{}
+}
+
+// newDistinctKeys returns a distinctKeys with room for n keys.
+func newDistinctKeys(n int) distinctKeys {
+	if n <= 0 {
+		return distinctKeys{}
+	}
+	return distinctKeys{keys: make([]string, 0, n), seen: make(map[string]struct{}, n)}
+}
+
+// add appends key to d.keys unless it was added before.
+func (d *distinctKeys) add(key string) {
+	if d.seen == nil {
+		d.seen = make(map[string]struct{})
+	}
+	if _, ok := d.seen[key]; ok {
+		return
+	}
+	d.seen[key] = struct{}{}
+	d.keys = append(d.keys, key)
+}
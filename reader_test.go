@@ -0,0 +1,119 @@
+package mph
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBuildFromReader(t *testing.T) {
+	r := strings.NewReader("foo\nbar\nfoo\r\nbaz\n\nquux")
+	table, err := BuildFromReader(r, 1.0, 1e-9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{"foo", "bar", "baz", "", "quux"}
+	if got := table.Len(); got != len(keys) {
+		t.Errorf("Len: got %d; want %d", got, len(keys))
+	}
+	for i, key := range keys {
+		if n, ok := table.Lookup(key); !ok || int(n) != i {
+			t.Errorf("Lookup(%q): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+}
+
+func TestBuildFromReader_longLine(t *testing.T) {
+	r := strings.NewReader("foo\nbar\n" + strings.Repeat("x", 100) + "\nbaz\n")
+	_, err := NewBuilder(WithMaxLineLen(64)).BuildFromReader(r)
+	if !errors.Is(err, bufio.ErrTooLong) {
+		t.Fatalf("got err=%v; want bufio.ErrTooLong", err)
+	}
+	if !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("error %q does not identify line 3", err)
+	}
+}
+
+func TestBuildFromChan(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for i, key := range keys {
+			ch <- key
+			if i%10 == 0 {
+				ch <- keys[i/2] // a repeat, which is ignored
+			}
+		}
+	}()
+	table, err := BuildFromChan(ch, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !table.Equal(want) {
+		t.Error("BuildFromChan gave a different table than Build")
+	}
+	for i, key := range keys {
+		if n, ok := table.Lookup(key); !ok || int(n) != i {
+			t.Fatalf("Lookup(%q): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+
+	empty := make(chan string)
+	close(empty)
+	if table, err := BuildFromChan(empty, 1.0, 0.01); err != nil || table.Len() != 0 {
+		t.Errorf("BuildFromChan of a closed channel: got (%v, %v); want an empty table", table, err)
+	}
+}
+
+func TestBuildFromReader_expectedKeys(t *testing.T) {
+	// The hint may be too low or too high.
+	for _, n := range []int{10, 1000, 100000} {
+		var sb strings.Builder
+		for i := 0; i < 1000; i++ {
+			sb.WriteString(strconv.Itoa(i) + "\n")
+		}
+		table, err := NewBuilder(WithExpectedKeys(n)).BuildFromReader(strings.NewReader(sb.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if table.Len() != 1000 {
+			t.Errorf("WithExpectedKeys(%d): got %d keys; want 1000", n, table.Len())
+		}
+	}
+}
+
+func BenchmarkBuildFromReader(b *testing.B) {
+	const numKeys = 100000
+	var sb strings.Builder
+	for i := 0; i < numKeys; i++ {
+		sb.WriteString(strconv.Itoa(i) + "\n")
+	}
+	input := sb.String()
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"noHint", nil},
+		{"expectedKeys", []Option{WithExpectedKeys(numKeys)}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			builder := NewBuilder(bench.opts...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := builder.BuildFromReader(strings.NewReader(input)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package mph
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// A reduction maps hashes onto the buckets or slots of a level array. The
+// reduction a table was built with is recorded in its encoding, since
+// lookups must use the same one to find the keys.
+type reduction byte
+
+const (
+	// reduceMod takes the hash modulo the array length.
+	reduceMod reduction = iota
+	// reduceMask masks the hash with the array length, a power of two,
+	// less one; see WithPowerOfTwoSizes.
+	reduceMask
+	// reduceRange scales the hash by the array length and keeps the high
+	// bits, as described by Lemire; see WithFastRange.
+	reduceRange
+)
+
+// index reduces the 32-bit hash h to an index below n.
+func (r reduction) index(h uint32, n int) int {
+	switch r {
+	case reduceMask:
+		return int(h & uint32(n-1))
+	case reduceRange:
+		return int(uint64(h) * uint64(n) >> 32)
+	}
+	return int(uint64(h) % uint64(n))
+}
+
+// index64 reduces the 64-bit hash h to an index below n.
+func (r reduction) index64(h uint64, n int) int {
+	switch r {
+	case reduceMask:
+		return int(h & uint64(n-1))
+	case reduceRange:
+		hi, _ := bits.Mul64(h, uint64(n))
+		return int(hi)
+	}
+	return int(h % uint64(n))
+}
+
+// sizes returns the lengths of the level arrays for a table of numKeys keys
+// built at the given load factor and level0 ratio with r.
+func (r reduction) sizes(numKeys int, loadFactor float32, level0Ratio float64) (level0Len, level1Len int) {
+	level0Len, level1Len = tableSizes(numKeys, loadFactor, level0Ratio)
+	if r == reduceMask {
+		level0Len = 1 << bits.Len(uint(level0Len-1))
+		level1Len = 1 << bits.Len(uint(level1Len-1))
+	}
+	return level0Len, level1Len
+}
+
+// check reports whether level arrays of the given lengths can be used with r.
+func (r reduction) check(level0Len, level1Len int) error {
+	switch r {
+	case reduceMod, reduceRange:
+		return nil
+	case reduceMask:
+		if level0Len&(level0Len-1) == 0 && level1Len&(level1Len-1) == 0 {
+			return nil
+		}
+		return errors.New("mph.UnmarshalBinary: level arrays of a masked table are not powers of two")
+	}
+	return errors.New("mph.UnmarshalBinary: unknown index reduction")
+}
@@ -0,0 +1,153 @@
+package mph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestWithPowerOfTwoSizes(t *testing.T) {
+	table := testReduction(t, WithPowerOfTwoSizes(), reduceMask)
+	if table.level0Len != 256 || table.level1Len != 1024 {
+		t.Errorf("Build: got level arrays of %d and %d; want 256 and 1024",
+			table.level0Len, table.level1Len)
+	}
+}
+
+func TestWithFastRange(t *testing.T) {
+	table := testReduction(t, WithFastRange(), reduceRange)
+	if table.level0Len != 250 || table.level1Len != 1000 {
+		t.Errorf("Build: got level arrays of %d and %d; want 250 and 1000",
+			table.level0Len, table.level1Len)
+	}
+}
+
+// testReduction builds a table and a Table64 with opt, checks that they and
+// their decodings use the reduction want, and that their lookups work. It
+// returns the table.
+func testReduction(t *testing.T, opt Option, want reduction) *Table {
+	t.Helper()
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b := NewBuilder(opt)
+	table, err := b.Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded, read Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read.ReadFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range []*Table{table, &decoded, &read} {
+		if tbl.reduction != want {
+			t.Errorf("got reduction %d; want %d", tbl.reduction, want)
+		}
+		if err := tbl.Verify(); err != nil {
+			t.Error(err)
+		}
+		for i, key := range keys {
+			if n, ok := tbl.Lookup(key); !ok || n != uint32(i) {
+				t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+	}
+
+	table64, err := b.Build64(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = table64.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	var decoded64 Table64
+	if err := decoded64.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range []*Table64{table64, &decoded64} {
+		if tbl.reduction != want {
+			t.Errorf("Table64: got reduction %d; want %d", tbl.reduction, want)
+		}
+		for i, key := range keys {
+			if n, ok := tbl.Lookup(key); !ok || n != uint64(i) {
+				t.Fatalf("Table64.Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+	}
+	return table
+}
+
+func TestReductionIndex(t *testing.T) {
+	for _, n := range []int{1, 7, 1000, 1 << 20} {
+		for _, h := range []uint64{0, 1, 1 << 31, math.MaxUint32, 1 << 63, math.MaxUint64} {
+			if got := reduceRange.index(uint32(h), n); got < 0 || got >= n {
+				t.Errorf("index(%#x, %d): got %d", uint32(h), n, got)
+			}
+			if got := reduceRange.index64(h, n); got < 0 || got >= n {
+				t.Errorf("index64(%#x, %d): got %d", h, n, got)
+			}
+		}
+		// The largest hashes map to the last index.
+		if got := reduceRange.index(math.MaxUint32, n); got != n-1 {
+			t.Errorf("index(MaxUint32, %d): got %d; want %d", n, got, n-1)
+		}
+		if got := reduceRange.index64(math.MaxUint64, n); got != n-1 {
+			t.Errorf("index64(MaxUint64, %d): got %d; want %d", n, got, n-1)
+		}
+	}
+}
+
+func TestUnmarshalBinary_flags(t *testing.T) {
+	table, err := Build([]string{"foo", "bar", "baz"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tables built without WithPowerOfTwoSizes needn't have power of two
+	// lengths, so masking them is invalid, as are unknown reductions and
+	// flags.
+	for _, flags := range []uint64{
+		uint64(reduceMask) << reductionShift,
+		reductionBits,
+		1 << 20,
+	} {
+		corrupt := bytes.Clone(data)
+		binary.LittleEndian.PutUint64(corrupt[wordOffset(ver, 4):], flags)
+		var decoded Table
+		if err := decoded.UnmarshalBinary(corrupt); err == nil {
+			t.Errorf("UnmarshalBinary with flags %#x: got nil error", flags)
+		}
+	}
+}
+
+func BenchmarkLookup_modulo(b *testing.B)     { benchmarkLookupReduction(b) }
+func BenchmarkLookup_powerOfTwo(b *testing.B) { benchmarkLookupReduction(b, WithPowerOfTwoSizes()) }
+func BenchmarkLookup_fastRange(b *testing.B)  { benchmarkLookupReduction(b, WithFastRange()) }
+
+func benchmarkLookupReduction(b *testing.B, opts ...Option) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := NewBuilder(opts...).Build(keys)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		table.index(keys[i%len(keys)])
+	}
+}
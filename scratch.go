@@ -0,0 +1,51 @@
+package mph
+
+import "sync"
+
+// A scratch holds the buffers used while building a table, which are pooled
+// so that building many small tables doesn't allocate them afresh each time.
+type scratch struct {
+	bucketOf []int // the bucket of each key
+	bounds   []int // the end of each bucket's keys in vals
+	vals     []int // key indices grouped by bucket
+	buckets  []indexBucket
+	occ      []bool
+	tmpOcc   []int
+	seen     map[string]struct{}
+	bh       bucketHasher
+}
+
+// maxPooledKeys bounds the size of the builds whose buffers are pooled, so
+// that building one huge table doesn't pin its buffers in memory.
+const maxPooledKeys = 1 << 16
+
+var scratchPool = sync.Pool{
+	New: func() any { return &scratch{seen: make(map[string]struct{})} },
+}
+
+// getScratch returns a scratch from the pool. Its buffers have arbitrary
+// contents; each is reset by the code which uses it.
+func getScratch() *scratch {
+	return scratchPool.Get().(*scratch)
+}
+
+// putScratch returns s to the pool, dropping its references to keys.
+func putScratch(s *scratch) {
+	if cap(s.vals) > maxPooledKeys {
+		return
+	}
+	clear(s.seen)
+	clear(s.bh.keys[:cap(s.bh.keys)])
+	s.bh.hasher = nil
+	clear(s.buckets[:cap(s.buckets)])
+	scratchPool.Put(s)
+}
+
+// resize returns a slice of length n, reusing the storage of s if it is large
+// enough. The elements are not cleared.
+func resize[E any](s []E, n int) []E {
+	if cap(s) < n {
+		return make([]E, n)
+	}
+	return s[:n]
+}
@@ -0,0 +1,57 @@
+package mph
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestBuild_pooledScratch(t *testing.T) {
+	small := []string{"foo", "bar", "baz", "quux", "a", "b"}
+	want, err := Build(small, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Builds of other sizes and with other hashers leave their state in
+	// the pooled buffers, which must not affect later builds.
+	large := make([]string, 5000)
+	for i := range large {
+		large[i] = strconv.Itoa(i)
+	}
+	if _, err := Build(large, 1.0, 0.01); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBuilder(WithHasher(fnvHasher{})).Build(large); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Build64(large, 0.8, 0.01); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Build(small, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("Build after other builds: got a different table than the first build")
+	}
+}
+
+func BenchmarkBuild_small(b *testing.B) {
+	tables := make([][]string, 1000)
+	for i := range tables {
+		keys := make([]string, 20)
+		for j := range keys {
+			keys[j] = strconv.Itoa(i*len(keys) + j)
+		}
+		tables[i] = keys
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, keys := range tables {
+			if _, err := Build(keys, 1.0, 0.01); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package mph
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// Shrink returns a minimal version of t, in which every level1 slot holds a
+// key, by placing the stored keys of t again at a load factor of 1.0. A
+// table built at a lower load factor, as Build falls back to when the seed
+// search fails at the one requested, has empty slots which cost memory and
+// encoding size. The result keeps the hasher, index reduction, bucket seed,
+// case folding, filter or fingerprints, and stored keys of t, so it answers
+// every lookup as t does, and shares no memory with it.
+//
+// If t is already minimal, was not built with BuildWithKeys or
+// BuildWithFrontCodedKeys, or its keys cannot be placed in exactly one slot
+// each, which a table built WithPowerOfTwoSizes can only do for a power of
+// two keys, Shrink returns t itself. Like a build, the seed search can take
+// long before it gives up.
+func (t *Table) Shrink() *Table {
+	if t.keyEnds == nil || t.ids || t.level1Len == t.numKeys {
+		return t
+	}
+	b := NewBuilder()
+	b.hasher, b.reduction, b.bucketSeed = t.hasher, t.reduction, t.bucketSeed
+	b.fallback, b.folding, b.fingerprintBits = t.fallback, t.folding, t.fingerprintBits
+	b.narrowSeeds = t.level0u16 != nil
+	if t.wideHash {
+		b.hashBits = 64
+	}
+	if _, level1Len := b.reduction.sizes(t.numKeys, 1, b.level0Ratio); level1Len != t.numKeys {
+		return t
+	}
+	keys := b.folding.applyAll(t.Keys())
+	s, _, err := b.buildInternal(context.Background(), keys, 1, cloneFilter(t.filter), 0)
+	if s == nil || err != nil {
+		return t
+	}
+	s.fpProb = t.fpProb
+	s.keyData = strings.Clone(t.keyData)
+	s.keyEnds = slices.Clone(t.keyEnds)
+	s.keyPrefixes = slices.Clone(t.keyPrefixes)
+	return s
+}
@@ -0,0 +1,84 @@
+package mph
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestShrink(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, build := range []func([]string, float32, float64) (*Table, error){BuildWithKeys, BuildWithFrontCodedKeys} {
+		table, err := build(keys, 0.5, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		level1Len := table.level1Len
+		shrunk := table.Shrink()
+		if shrunk == table {
+			t.Fatal("Shrink: got the original table")
+		}
+		if shrunk.level1Len != len(keys) || shrunk.LoadFactor() != 1 {
+			t.Errorf("Shrink: got %d level1 slots at load factor %v; want %d at 1", shrunk.level1Len, shrunk.LoadFactor(), len(keys))
+		}
+		if table.level1Len != level1Len {
+			t.Errorf("Shrink changed the original table's level1Len from %d to %d", level1Len, table.level1Len)
+		}
+		if err := shrunk.Verify(); err != nil {
+			t.Error(err)
+		}
+		for i, key := range keys {
+			if n, ok := shrunk.LookupExact(key); !ok || n != uint32(i) {
+				t.Fatalf("LookupExact(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+		if got, want := shrunk.FalsePositiveRate(), table.FalsePositiveRate(); got != want {
+			t.Errorf("FalsePositiveRate: got %v; want %v", got, want)
+		}
+		data, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		shrunkData, err := shrunk.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shrunkData) >= len(data) {
+			t.Errorf("MarshalBinary: got %d bytes after Shrink; want fewer than %d", len(shrunkData), len(data))
+		}
+	}
+
+	// Options of the table carry over.
+	table, err := NewBuilder(WithLoadFactor(0.5), WithCaseFold(), WithFingerprint(16), WithBucketSeed(7)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.setKeys(keys)
+	shrunk := table.Shrink()
+	if shrunk.level1Len != len(keys) || shrunk.fingerprintBits != 16 || shrunk.bucketSeed != 7 || !shrunk.folding.caseFold {
+		t.Errorf("Shrink of a table with options: got %d slots, %d-bit fingerprints, bucket seed %d, and case folding %t",
+			shrunk.level1Len, shrunk.fingerprintBits, shrunk.bucketSeed, shrunk.folding.caseFold)
+	}
+
+	// Tables which cannot be shrunk are returned as they are.
+	minimal, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noKeys, err := Build(keys, 0.5, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	masked, err := NewBuilder(WithLoadFactor(0.5), WithPowerOfTwoSizes()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	masked.setKeys(keys)
+	for name, table := range map[string]*Table{"a minimal table": minimal, "a table without keys": noKeys, "a masked table": masked} {
+		if table.Shrink() != table {
+			t.Errorf("Shrink of %s: got a new table", name)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package mph
+
+import (
+	"fmt"
+
+	"github.com/golang/snappy"
+)
+
+// MarshalSnappy returns the encoding of MarshalBinary compressed in snappy's
+// block format. It is a convenience for storing many tables; the
+// uncompressed encoding remains the canonical one. Unlike the encoding of
+// Marshal with Snappy, the result carries no marker of its compression, so
+// only UnmarshalSnappy decodes it.
+func (t *Table) MarshalSnappy() ([]byte, error) {
+	data, err := t.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return snappy.Encode(nil, data), nil
+}
+
+// maxDecodedLen bounds the length of the uncompressed encoding which
+// UnmarshalSnappy and Unmarshal accept, so that a small corrupt or malicious
+// input cannot make them allocate without limit. It is a variable so that
+// tests can lower it.
+var maxDecodedLen uint64 = maxEncodedLen
+
+// UnmarshalSnappy decodes a table encoded by MarshalSnappy.
+func UnmarshalSnappy(data []byte) (*Table, error) {
+	raw, err := decodeSnappy(data)
+	if err != nil {
+		return nil, fmt.Errorf("mph.UnmarshalSnappy: %w", err)
+	}
+	// raw is ours, so the table may refer to it rather than copy it.
+	t := new(Table)
+	if err := t.UnmarshalBinaryNoCopy(raw); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// decodeSnappy decodes data in snappy's block format, checking the length
+// which it claims to decode to against maxDecodedLen before allocating it.
+func decodeSnappy(data []byte) ([]byte, error) {
+	n, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n) > maxDecodedLen {
+		return nil, fmt.Errorf("%w: decompresses to %d bytes, more than the limit of %d", ErrDecodedTooLarge, n, maxDecodedLen)
+	}
+	return snappy.Decode(nil, data)
+}
@@ -0,0 +1,87 @@
+package mph
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/golang/snappy"
+)
+
+func TestMarshalSnappy(t *testing.T) {
+	// Keys like URLs, which share long prefixes, are typical of tables
+	// which store their keys.
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("https://example.com/products/%d/reviews", i)
+	}
+	table, err := BuildWithKeys(keys, 0.9, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := table.MarshalSnappy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("MarshalBinary: %d bytes; MarshalSnappy: %d bytes", len(data), len(compressed))
+	if len(compressed) >= len(data)/2 {
+		t.Errorf("MarshalSnappy: got %d bytes; want less than half of the %d of MarshalBinary", len(compressed), len(data))
+	}
+	decoded, err := UnmarshalSnappy(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Equal(table) {
+		t.Error("UnmarshalSnappy: got a different table")
+	}
+	for i, key := range keys {
+		if n, ok := decoded.LookupExact(key); !ok || n != uint32(i) {
+			t.Fatalf("LookupExact(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+
+	if _, err := UnmarshalSnappy(data); err == nil {
+		t.Error("UnmarshalSnappy of uncompressed data: got nil error")
+	}
+	if _, err := UnmarshalSnappy(compressed[:len(compressed)-1]); err == nil {
+		t.Error("UnmarshalSnappy of truncated data: got nil error")
+	}
+}
+
+func TestUnmarshalSnappy_oversized(t *testing.T) {
+	table, err := Build([]string{"a", "b", "c"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := table.MarshalSnappy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(max uint64) { maxDecodedLen = max }(maxDecodedLen)
+	maxDecodedLen = uint64(n)
+	if _, err := UnmarshalSnappy(compressed); err != nil {
+		t.Fatalf("UnmarshalSnappy at the limit: %v", err)
+	}
+	maxDecodedLen = uint64(n) - 1
+	if _, err := UnmarshalSnappy(compressed); !errors.Is(err, ErrDecodedTooLarge) {
+		t.Errorf("UnmarshalSnappy above the limit: got err=%v; want ErrDecodedTooLarge", err)
+	}
+
+	// A frame claiming the largest length snappy allows is rejected from its
+	// header, without allocating that much.
+	maxDecodedLen = 1 << 20
+	frame := binary.AppendUvarint(nil, math.MaxUint32)
+	if _, err := UnmarshalSnappy(frame); !errors.Is(err, ErrDecodedTooLarge) {
+		t.Errorf("UnmarshalSnappy of a frame claiming %d bytes: got err=%v; want ErrDecodedTooLarge", uint32(math.MaxUint32), err)
+	}
+}
@@ -0,0 +1,124 @@
+package mph
+
+import "unsafe"
+
+// Stats describes the internals of a Table, for tuning and diagnosing builds.
+type Stats struct {
+	// Keys is the number of keys in the table.
+	Keys int
+	// Level0Len is the number of level0 buckets, each with its own seed.
+	Level0Len int
+	// Level1Len is the number of level1 slots, at least Keys.
+	Level1Len int
+	// LoadFactor is the ratio of Keys to Level1Len. It may be lower than
+	// the load factor requested if the build had to lower it.
+	LoadFactor float64
+
+	// MaxBucketSize is the number of keys in the largest level0 bucket.
+	// Much more than the average of Keys over Level0Len suggests a hash
+	// which suits the keys poorly: the largest buckets dominate the build
+	// time, so try another Hasher, bucket seed or level0 ratio.
+	MaxBucketSize int
+	// SeedAttempts is the number of seeds, and fallback probes, tried over
+	// all buckets while placing the keys at the final load factor.
+	SeedAttempts uint64
+	// TotalSeedAttempts is SeedAttempts plus the seeds tried at the load
+	// factors which were abandoned because the seed search was exhausted,
+	// so it exceeds SeedAttempts only if the build had to lower the load
+	// factor. A high value suggests a pathological key distribution or too
+	// high a load factor.
+	TotalSeedAttempts uint64
+	// FallbackBuckets is the number of buckets placed by the fallback search
+	// of WithProbingFallback.
+	FallbackBuckets int
+
+	// MemoryBytes estimates the memory used by the table, as reported by
+	// MemoryUsage.
+	MemoryBytes int
+}
+
+// A buildStats records the parts of Stats which are only known while building.
+type buildStats struct {
+	maxBucketSize   int
+	seedAttempts    uint64
+	fallbackBuckets int
+
+	// totalSeedAttempts is seedAttempts plus the attempts at the load
+	// factors tried before.
+	totalSeedAttempts uint64
+
+	// If the seed search was exhausted, placedBuckets is the number of
+	// buckets placed before it, of numBuckets, and stuckBucketSize the
+	// number of keys in the bucket for which it was.
+	placedBuckets, numBuckets, stuckBucketSize int
+}
+
+// bucketStats computes the buildStats of a table with the given buckets,
+// largest first, and seeds. Each bucket's seed is the number of seeds which
+// were rejected for it, unless fallback is set and the seed has fallbackBit
+// set, in which case all limit seeds were rejected, followed by the number of
+// probes in the remaining bits.
+func bucketStats(buckets []indexBucket, level0 []uint32, fallback bool, limit uint32) buildStats {
+	var stats buildStats
+	if len(buckets) > 0 {
+		stats.maxBucketSize = len(buckets[0].vals)
+	}
+	for _, bucket := range buckets {
+		seed := level0[bucket.n]
+		if fallback && seed&fallbackBit != 0 {
+			stats.seedAttempts += uint64(limit)
+			stats.fallbackBuckets++
+			seed &^= fallbackBit
+		}
+		stats.seedAttempts += uint64(seed) + 1
+	}
+	return stats
+}
+
+// Stats returns statistics about t. MaxBucketSize and the seed attempts are
+// only known for tables built in this process; they are zero for decoded
+// tables. Like MemoryUsage, Stats is not meant for hot paths.
+func (t *Table) Stats() Stats {
+	return Stats{
+		Keys:            t.numKeys,
+		Level0Len:       t.level0Len,
+		Level1Len:       t.level1Len,
+		MaxBucketSize:   t.build.maxBucketSize,
+		SeedAttempts:    t.build.seedAttempts,
+		FallbackBuckets: t.build.fallbackBuckets,
+		MemoryBytes:     t.MemoryUsage(),
+
+		TotalSeedAttempts: t.build.totalSeedAttempts,
+		LoadFactor:        t.Density(),
+	}
+}
+
+// Density returns the fraction of t's level1 slots which hold a key, 1 for
+// a minimal table. Since each key occupies exactly one slot, no occupancy
+// needs to be recorded: it is the number of keys over the number of slots.
+// It may be below the load factor at which t was built when the sizes of
+// the level arrays are rounded, as WithPowerOfTwoSizes does. Density returns
+// 0 for an empty Table.
+func (t *Table) Density() float64 {
+	if t.level1Len == 0 {
+		return 0
+	}
+	return float64(t.numKeys) / float64(t.level1Len)
+}
+
+// MemoryUsage returns the approximate number of bytes of memory used by t:
+// its level arrays, bloom filter, and stored keys, and the Table itself. For
+// a table opened with OpenMmap, the level arrays and keys are in the mapping
+// rather than on the heap. The bloom filter's size is estimated from the
+// length of its encoding, so MemoryUsage is not meant for hot paths.
+func (t *Table) MemoryUsage() int {
+	n := int(unsafe.Sizeof(*t)) + (len(t.level0)+len(t.level1))*bphw + len(t.level0u16)*bpqw +
+		len(t.fingerprints) + len(t.keyData) + (len(t.keyEnds)+len(t.keyPrefixes))*int(unsafe.Sizeof(0))
+	if t.filter != nil {
+		n += int(unsafe.Sizeof(*t.filter))
+		if bd, err := t.filter.MarshalBinary(); err == nil {
+			n += len(bd)
+		}
+	}
+	return n
+}
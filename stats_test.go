@@ -0,0 +1,208 @@
+package mph
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := table.Stats()
+	if s.Keys != len(keys) || s.Level1Len != len(keys) || s.Level0Len != len(keys)/4 {
+		t.Errorf("Stats: got Keys=%d Level0Len=%d Level1Len=%d; want %d, %d, %d",
+			s.Keys, s.Level0Len, s.Level1Len, len(keys), len(keys)/4, len(keys))
+	}
+	if s.LoadFactor != 1.0 {
+		t.Errorf("Stats: got LoadFactor=%v; want 1", s.LoadFactor)
+	}
+	if s.MaxBucketSize < 4 || s.MaxBucketSize > 20 {
+		t.Errorf("Stats: got MaxBucketSize=%d; want a few keys", s.MaxBucketSize)
+	}
+	// Every non-empty bucket needs at least one attempt, and most buckets
+	// are non-empty.
+	if s.SeedAttempts < uint64(s.Level0Len/2) {
+		t.Errorf("Stats: got SeedAttempts=%d; want at least %d", s.SeedAttempts, s.Level0Len/2)
+	}
+	if levels := (s.Level0Len + s.Level1Len) * 4; s.MemoryBytes < levels || s.MemoryBytes > 4*levels {
+		t.Errorf("Stats: got MemoryBytes=%d; want between %d and %d", s.MemoryBytes, levels, 4*levels)
+	}
+
+	parallel, err := BuildParallel(keys, 1.0, 0.01, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parallel.Stats(); got != s {
+		t.Errorf("BuildParallel Stats: got %+v; want %+v", got, s)
+	}
+
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	want := s
+	want.MaxBucketSize, want.SeedAttempts, want.TotalSeedAttempts = 0, 0, 0
+	if got := decoded.Stats(); got != want {
+		t.Errorf("Stats after UnmarshalBinary: got %+v; want %+v", got, want)
+	}
+}
+
+func TestStats_totalSeedAttempts(t *testing.T) {
+	keys := make([]string, 200)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	opts := []Option{WithLoadFactor(0.8), WithMaxSeedAttempts(10000)}
+	table, err := NewBuilder(opts...).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := table.Stats()
+	buckets := make(map[int]bool)
+	for _, key := range keys {
+		buckets[table.reduction.index(hashString(nil, 0, key), table.level0Len)] = true
+	}
+	if s.TotalSeedAttempts != s.SeedAttempts || s.SeedAttempts < uint64(len(buckets)) {
+		t.Errorf("Stats: got SeedAttempts=%d TotalSeedAttempts=%d; want equal and at least the %d buckets",
+			s.SeedAttempts, s.TotalSeedAttempts, len(buckets))
+	}
+	if parallel, err := BuildParallel(keys, 0.8, 0.01, 4); err != nil {
+		t.Fatal(err)
+	} else if got := parallel.Stats().TotalSeedAttempts; got != s.TotalSeedAttempts {
+		t.Errorf("BuildParallel: got TotalSeedAttempts=%d; want %d", got, s.TotalSeedAttempts)
+	}
+
+	// Clustering the keys into a few large buckets makes their seeds much
+	// harder to find, exhausting the search at the requested load factor,
+	// and every attempt made there is counted.
+	clustered, err := NewBuilder(append(opts, WithLevel0Ratio(25))...).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := clustered.Stats()
+	if clustered.LoadFactor() == 0.8 || cs.TotalSeedAttempts < cs.SeedAttempts+10000 {
+		t.Errorf("clustered keys: got load factor %v, SeedAttempts=%d, TotalSeedAttempts=%d; want a lower load factor and at least 10000 more in total",
+			clustered.LoadFactor(), cs.SeedAttempts, cs.TotalSeedAttempts)
+	}
+	if cs.TotalSeedAttempts <= s.TotalSeedAttempts {
+		t.Errorf("clustered keys: got TotalSeedAttempts=%d; want more than the %d for unclustered keys",
+			cs.TotalSeedAttempts, s.TotalSeedAttempts)
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	usage := func(numKeys int) int {
+		keys := make([]string, numKeys)
+		for i := range keys {
+			keys[i] = strconv.Itoa(i)
+		}
+		table, err := Build(keys, 1.0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return table.MemoryUsage()
+	}
+	small, large := usage(10000), usage(100000)
+	// The level arrays take 5 bytes per key, and the bloom filter about
+	// 1.2 more at a false positive rate of 1%.
+	if small < 10000*5 || small > 10000*10 {
+		t.Errorf("MemoryUsage for 10000 keys: got %d; want between %d and %d", small, 10000*5, 10000*10)
+	}
+	if ratio := float64(large) / float64(small); ratio < 8 || ratio > 12 {
+		t.Errorf("MemoryUsage: got %d for 10000 keys and %d for 100000; want about 10 times as much",
+			small, large)
+	}
+}
+
+func TestDensity(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	minimal, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := minimal.Density(); got != 1 {
+		t.Errorf("Density of a minimal table: got %v; want 1", got)
+	}
+	sparse, err := Build(keys, 0.5, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sparse.Density(); got > 0.51 || got < 0.49 {
+		t.Errorf("Density at load factor 0.5: got %v; want about 0.5", got)
+	}
+	// Rounding up to powers of two makes a table sparser than requested.
+	masked, err := NewBuilder(WithPowerOfTwoSizes()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := masked.Density(), 1000.0/1024; got != want {
+		t.Errorf("Density with power of two sizes: got %v; want %v", got, want)
+	}
+	if got := new(Table).Density(); got != 0 {
+		t.Errorf("Density of an empty Table: got %v; want 0", got)
+	}
+}
+
+// A skewedHasher assigns the keys from strconv.Itoa of multiples of 20 to
+// the same level0 bucket when the seed is skewSeed, and hashes like
+// fnvHasher otherwise.
+type skewedHasher struct{ fnvHasher }
+
+const skewSeed = 0x5eed
+
+func (h skewedHasher) Hash(seed uint32, data []byte) uint32 {
+	if n, err := strconv.Atoi(string(data)); seed == skewSeed && err == nil && n%20 == 0 {
+		return 0
+	}
+	return h.fnvHasher.Hash(seed, data)
+}
+
+func (skewedHasher) ID() byte { return 205 }
+
+func init() {
+	RegisterHasher(skewedHasher{})
+}
+
+func TestStats_maxBucketSize(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	// With a uniform hash, the largest of the 250 buckets has a few keys
+	// more than the average of 4.
+	uniform, err := NewBuilder(WithHasher(skewedHasher{})).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := uniform.Stats()
+	if avg := s.Keys / s.Level0Len; s.MaxBucketSize < avg || s.MaxBucketSize > 4*avg {
+		t.Errorf("uniform keys: got MaxBucketSize=%d; want near the average of %d", s.MaxBucketSize, avg)
+	}
+
+	// The 50 multiples of 20 share a bucket.
+	skewed, err := NewBuilder(WithHasher(skewedHasher{}), WithBucketSeed(skewSeed)).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := skewed.Stats().MaxBucketSize; got < 50 {
+		t.Errorf("skewed keys: got MaxBucketSize=%d; want at least 50", got)
+	}
+	for i, key := range keys {
+		if n, ok := skewed.Lookup(key); !ok || n != uint32(i) {
+			t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+}
@@ -0,0 +1,603 @@
+package mph
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// streamBufSize is the size of the buffer used to encode and decode the
+// level arrays in WriteTo and ReadFrom.
+const streamBufSize = 4096
+
+// WriteTo writes t to w in the encoding produced by MarshalBinary, without
+// first assembling the whole encoding in memory. It returns the number of
+// bytes written and any error encountered.
+func (t *Table) WriteTo(w io.Writer) (int64, error) {
+	bd, err := marshalFilter(t.filter)
+	if err != nil {
+		return 0, err
+	}
+	h := t.header(len(bd))
+	cw := &countingWriter{w: w}
+	buf := make([]byte, streamBufSize)
+	h.put(buf)
+	cw.write(buf[:headerLen(ver)])
+	cw.write(bd)
+	var pad [levelAlign]byte
+	cw.write(pad[:padLen(ver, int(cw.n))])
+	if t.level0u16 != nil {
+		cw.writeUint16s(t.level0u16, buf)
+	} else {
+		cw.writeUint32s(t.level0, buf)
+	}
+	cw.write(pad[:padLen(ver, int(cw.n))])
+	cw.writeUint32s(t.level1, buf)
+	cw.write(t.fingerprints)
+	if h.flags&flagKeys != 0 {
+		cw.writeKeys(t, buf)
+	}
+	cw.write(binary.LittleEndian.AppendUint32(buf[:0], cw.crc))
+	return cw.n, cw.err
+}
+
+// WriteChunked is like WriteTo but writes a chunked encoding, meant for large
+// tables, in which the header is followed by its own checksum and each part
+// of the table, its bloom filter, level arrays, fingerprints, and stored
+// keys, is a chunk framed by its length and followed by its own checksum,
+// rather than the whole encoding sharing one. ReadFrom then reports a corrupt
+// or truncated part with a *ChunkError naming it, which tells which part of
+// the stored data was damaged. UnmarshalBinary and OpenMmap also accept the
+// encoding, but since its level arrays are not aligned they always copy them.
+func (t *Table) WriteChunked(w io.Writer) (int64, error) {
+	bd, err := marshalFilter(t.filter)
+	if err != nil {
+		return 0, err
+	}
+	h := t.header(len(bd))
+	h.flags |= flagChunked
+	cw := &countingWriter{w: w}
+	buf := make([]byte, streamBufSize)
+	h.put(buf)
+	cw.write(buf[:headerLen(ver)])
+	cw.write(binary.LittleEndian.AppendUint32(buf[:0], cw.crc))
+	cw.writeChunk(len(bd), func() { cw.write(bd) })
+	if t.level0u16 != nil {
+		cw.writeChunk(len(t.level0u16)*bpqw, func() { cw.writeUint16s(t.level0u16, buf) })
+	} else {
+		cw.writeChunk(len(t.level0)*bphw, func() { cw.writeUint32s(t.level0, buf) })
+	}
+	cw.writeChunk(len(t.level1)*bphw, func() { cw.writeUint32s(t.level1, buf) })
+	if t.fingerprints != nil {
+		cw.writeChunk(len(t.fingerprints), func() { cw.write(t.fingerprints) })
+	}
+	if h.flags&flagKeys != 0 {
+		cw.writeChunk(t.keysLen(), func() { cw.writeKeys(t, buf) })
+	}
+	return cw.n, cw.err
+}
+
+// A countingWriter counts the bytes written to w, and their checksum, and
+// records the first error, after which writes are ignored.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	crc uint32
+	err error
+}
+
+func (cw *countingWriter) write(p []byte) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.crc = crc32.Update(cw.crc, crc32.IEEETable, p[:n])
+	cw.err = err
+}
+
+// writeChunk writes a chunk of a chunked encoding whose n bytes are written by
+// f: its length, the bytes, and their checksum.
+func (cw *countingWriter) writeChunk(n int, f func()) {
+	var frame [bpw]byte
+	binary.LittleEndian.PutUint64(frame[:], uint64(n))
+	cw.write(frame[:])
+	cw.crc = 0
+	f()
+	binary.LittleEndian.PutUint32(frame[:], cw.crc)
+	cw.write(frame[:checksumLen])
+}
+
+// writeKeys writes the stored keys of t as MarshalBinary encodes them, using
+// buf as scratch space.
+func (cw *countingWriter) writeKeys(t *Table, buf []byte) {
+	buf = buf[:0]
+	for _, p := range t.keyPrefixes {
+		if len(buf) > streamBufSize-binary.MaxVarintLen64 {
+			cw.write(buf)
+			buf = buf[:0]
+		}
+		buf = binary.AppendUvarint(buf, uint64(p))
+	}
+	var prev int
+	for _, end := range t.keyEnds {
+		if len(buf) > streamBufSize-binary.MaxVarintLen64 {
+			cw.write(buf)
+			buf = buf[:0]
+		}
+		buf = binary.AppendUvarint(buf, uint64(end-prev))
+		prev = end
+	}
+	cw.write(buf)
+	cw.write(unsafeBytes(t.keyData))
+}
+
+// keysLen returns the length of the stored keys of t as writeKeys writes them.
+func (t *Table) keysLen() int {
+	n := len(t.keyData)
+	for _, p := range t.keyPrefixes {
+		n += uvarintLen(uint64(p))
+	}
+	var prev int
+	for _, end := range t.keyEnds {
+		n += uvarintLen(uint64(end - prev))
+		prev = end
+	}
+	return n
+}
+
+// uvarintLen returns the length of the uvarint encoding of v.
+func uvarintLen(v uint64) int {
+	n := 1
+	for ; v >= 0x80; v >>= 7 {
+		n++
+	}
+	return n
+}
+
+// writeUint32s writes vs in little-endian order using buf as scratch space.
+func (cw *countingWriter) writeUint32s(vs []uint32, buf []byte) {
+	per := len(buf) / bphw
+	for len(vs) > 0 && cw.err == nil {
+		chunk := vs[:min(per, len(vs))]
+		for i, v := range chunk {
+			binary.LittleEndian.PutUint32(buf[i*bphw:], v)
+		}
+		cw.write(buf[:len(chunk)*bphw])
+		vs = vs[len(chunk):]
+	}
+}
+
+// writeUint16s is like writeUint32s but for uint16s.
+func (cw *countingWriter) writeUint16s(vs []uint16, buf []byte) {
+	per := len(buf) / bpqw
+	for len(vs) > 0 && cw.err == nil {
+		chunk := vs[:min(per, len(vs))]
+		for i, v := range chunk {
+			binary.LittleEndian.PutUint16(buf[i*bpqw:], v)
+		}
+		cw.write(buf[:len(chunk)*bpqw])
+		vs = vs[len(chunk):]
+	}
+}
+
+// ReadFrom reads a table written by WriteTo, WriteChunked, or MarshalBinary
+// from r, replacing the contents of t. It reads exactly the bytes of the
+// encoded table and returns their number. Reaching the end of r before the
+// end of the table is reported as io.ErrUnexpectedEOF. Like UnmarshalBinary,
+// it rejects data without the magic of the encoding unless AllowLegacyFormat
+// is set, reuses the memory of t's level arrays, and leaves t empty on error.
+func (t *Table) ReadFrom(r io.Reader) (int64, error) {
+	t.Reset()
+	cr := &countingReader{r: r}
+	err := t.readFrom(cr)
+	if err == io.EOF && cr.n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return cr.n, err
+}
+
+// DecodeFrom is like ReadFrom but returns only the error, for callers which
+// don't need the count. Like ReadFrom, and unlike UnmarshalBinary, it never
+// holds the whole encoding in memory: the level arrays are read in chunks
+// straight into t's, so a large table can be decoded from a file without
+// first reading the file.
+func (t *Table) DecodeFrom(r io.Reader) error {
+	_, err := t.ReadFrom(r)
+	return err
+}
+
+func (t *Table) readFrom(cr *countingReader) error {
+	buf := make([]byte, streamBufSize)
+	if _, err := io.ReadFull(cr, buf[:1]); err != nil {
+		return err
+	}
+	if err := checkLegacy(buf[:1]); err != nil {
+		return err
+	}
+	n := 1
+	if buf[0] == magic[0] {
+		n = len(magic) + 1
+		if _, err := io.ReadFull(cr, buf[1:n]); err != nil {
+			return err
+		}
+	}
+	v, err := encodingVersion(buf[:n])
+	if err != nil {
+		return err
+	}
+	hl := headerLen(v)
+	if _, err := io.ReadFull(cr, buf[n:hl]); err != nil {
+		return err
+	}
+	// A chunked header is followed by its checksum, which is checked before
+	// any of its fields are trusted.
+	chunked := chunkedFlag(v, buf[:hl])
+	if chunked {
+		crc := cr.crc
+		if _, err := io.ReadFull(cr, buf[hl:hl+checksumLen]); err != nil {
+			return &ChunkError{Chunk: "header", Err: unexpectedEOF(err)}
+		}
+		if crc != binary.LittleEndian.Uint32(buf[hl:]) {
+			return &ChunkError{Chunk: "header", Err: errChecksum}
+		}
+	}
+	h, err := parseHeader(buf[:hl])
+	if err != nil {
+		return err
+	}
+	if h.flags&flagWideIndex != 0 {
+		return errors.New("mph.ReadFrom: table has 64-bit indices; use Table64")
+	}
+	hasher, err := h.hasher()
+	if err != nil {
+		return err
+	}
+	reduction, err := h.reduction()
+	if err != nil {
+		return err
+	}
+	folding, err := h.folding()
+	if err != nil {
+		return err
+	}
+	// Each part of a chunked encoding is read as a chunk.
+	part := func(name string, want int, read func() error) error {
+		if chunked {
+			return cr.readChunk(name, want, buf, read)
+		}
+		return read()
+	}
+	if chunked && h.flags&flagCompact != 0 {
+		return fmt.Errorf("%w: a chunked encoding cannot be compact", ErrInvalidTable)
+	}
+	var bd []byte
+	err = part("bloom", h.bloomLen, func() (err error) {
+		bd, err = cr.readBytes(h.bloomLen)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	filter, err := unmarshalFilter(bd)
+	if err != nil {
+		return err
+	}
+	level0, level0u16, level1 := t.level0, t.level0u16, t.level1
+	narrow := h.flags&flagNarrowSeeds != 0
+	if h.flags&flagCompact != 0 {
+		if narrow {
+			level0u16, err = readUvarints(cr, level0u16, h.level0Len)
+		} else {
+			level0, err = readUvarints(cr, level0, h.level0Len)
+		}
+		if err != nil {
+			return err
+		}
+		if level1, err = readUvarints(cr, level1, h.level1Len); err != nil {
+			return err
+		}
+	} else {
+		if !chunked {
+			if err := cr.skipPadding(h.version, buf); err != nil {
+				return err
+			}
+		}
+		if narrow {
+			err = part("level0", h.level0Len*bpqw, func() (err error) {
+				level0u16, err = cr.readUint16s(level0u16, h.level0Len, buf)
+				return err
+			})
+		} else {
+			err = part("level0", h.level0Len*bphw, func() (err error) {
+				level0, err = cr.readUint32s(level0, h.level0Len, buf)
+				return err
+			})
+		}
+		if err != nil {
+			return err
+		}
+		if !chunked {
+			if err := cr.skipPadding(h.version, buf); err != nil {
+				return err
+			}
+		}
+		err = part("level1", h.level1Len*bphw, func() (err error) {
+			level1, err = cr.readUint32s(level1, h.level1Len, buf)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// Only one of the seed arrays is kept, so that lookups can tell which.
+	if narrow {
+		level0 = nil
+	} else {
+		level0u16 = nil
+	}
+	fingerprintBits, err := h.fingerprintBits()
+	if err != nil {
+		return err
+	}
+	var fingerprints []byte
+	if fingerprintBits != 0 {
+		err = part("fingerprints", h.fingerprintsLen(), func() (err error) {
+			fingerprints, err = cr.readBytes(h.fingerprintsLen())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	numKeys, loadFactor := h.numKeys, h.loadFactor
+	if h.version == 1 {
+		if numKeys = keyCountV1(level1); numKeys > len(level1) {
+			return fmt.Errorf("%w: index %d in %d level1 slots", ErrInvalidTable, numKeys-1, len(level1))
+		}
+	}
+	if h.version < 6 {
+		loadFactor = estimateLoadFactor(numKeys, len(level1))
+	}
+	var (
+		keyData     string
+		keyEnds     []int
+		keyPrefixes []int
+	)
+	readKeys := func() error {
+		if h.flags&flagFrontCoded != 0 {
+			keyPrefixes = make([]int, 0, min(numKeys, streamBufSize))
+			for i := 0; i < numKeys; i++ {
+				p, err := binary.ReadUvarint(cr)
+				if err != nil {
+					return err
+				}
+				if p > maxEncodedLen {
+					return errors.New("mph.ReadFrom: bad key prefix lengths")
+				}
+				keyPrefixes = append(keyPrefixes, int(p))
+			}
+		}
+		keyEnds = make([]int, 0, min(numKeys, streamBufSize))
+		var end int
+		for i := 0; i < numKeys; i++ {
+			n, err := binary.ReadUvarint(cr)
+			if err != nil {
+				return err
+			}
+			if n > maxEncodedLen-uint64(end) {
+				return errors.New("mph.ReadFrom: bad key lengths")
+			}
+			end += int(n)
+			keyEnds = append(keyEnds, end)
+		}
+		data, err := cr.readBytes(end)
+		if err != nil {
+			return err
+		}
+		keyData = string(data)
+		if keyPrefixes != nil {
+			return checkPrefixes(keyPrefixes, keyEnds)
+		}
+		return nil
+	}
+	if h.flags&flagKeys != 0 {
+		if err := part("keys", -1, readKeys); err != nil {
+			return err
+		}
+	}
+	if h.version >= 4 && !chunked {
+		crc := cr.crc
+		if _, err := io.ReadFull(cr, buf[:checksumLen]); err != nil {
+			return err
+		}
+		if crc != binary.LittleEndian.Uint32(buf) {
+			return errChecksum
+		}
+	}
+	*t = Table{
+		filter:    filter,
+		hasher:    hasher,
+		reduction: reduction,
+		level0:    level0,
+		level0u16: level0u16,
+		level0Len: h.level0Len,
+		level1:    level1,
+		level1Len: len(level1),
+		numKeys:   numKeys,
+		fallback:  h.flags&flagFallback != 0,
+		ids:       h.flags&flagIDs != 0,
+		wideHash:  h.flags&flagWideHash != 0,
+		folding:   folding,
+		keyData:   keyData,
+		keyEnds:   keyEnds,
+
+		keyPrefixes: keyPrefixes,
+
+		loadFactor: loadFactor,
+		fpProb:     h.fpProb,
+		bucketSeed: h.bucketSeed,
+
+		fingerprints:    fingerprints,
+		fingerprintBits: fingerprintBits,
+
+		mapping: t.mapping,
+	}
+	return nil
+}
+
+// A countingReader counts the bytes read from r, and their checksum. It
+// implements io.ByteReader without reading ahead, so that no bytes past the
+// end of a table are consumed.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	crc uint32
+	b   [1]byte
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	cr.crc = crc32.Update(cr.crc, crc32.IEEETable, p[:n])
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(cr, cr.b[:]); err != nil {
+		return 0, err
+	}
+	return cr.b[0], nil
+}
+
+// readChunk reads a chunk of a chunked encoding, decoding its bytes with
+// read, and checks their length, which must be want unless it is negative,
+// and their checksum. Its errors are *ChunkErrors naming the chunk. It uses
+// buf, which read may also use, as scratch space.
+func (cr *countingReader) readChunk(name string, want int, buf []byte, read func() error) error {
+	err := func() error {
+		if _, err := io.ReadFull(cr, buf[:bpw]); err != nil {
+			return err
+		}
+		n := binary.LittleEndian.Uint64(buf)
+		if want >= 0 && n != uint64(want) || n > maxEncodedLen {
+			return fmt.Errorf("%w: framed as %d bytes, want %d", ErrInvalidTable, n, want)
+		}
+		start := cr.n
+		cr.crc = 0
+		if err := read(); err != nil {
+			return err
+		}
+		if got := cr.n - start; got != int64(n) {
+			return fmt.Errorf("%w: framed as %d bytes, decoded %d", ErrInvalidTable, n, got)
+		}
+		crc := cr.crc
+		if _, err := io.ReadFull(cr, buf[:checksumLen]); err != nil {
+			return err
+		}
+		if crc != binary.LittleEndian.Uint32(buf) {
+			return errChecksum
+		}
+		return nil
+	}()
+	if err != nil {
+		return &ChunkError{Chunk: name, Err: unexpectedEOF(err)}
+	}
+	return nil
+}
+
+// chunkedFlag reports whether header, the first headerLen(v) bytes of an
+// encoding in version v, has flagChunked set. It reads only that flag, so
+// that the checksum of a chunked header can be checked before parseHeader
+// validates the rest.
+func chunkedFlag(v byte, header []byte) bool {
+	return v >= 3 && binary.LittleEndian.Uint64(header[wordOffset(v, 4):])&flagChunked != 0
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF for io.EOF, which within a table
+// means that it was truncated, and err otherwise.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// skipPadding reads the padding which precedes a level array in the given
+// encoding version using buf as scratch space.
+func (cr *countingReader) skipPadding(version byte, buf []byte) error {
+	_, err := io.ReadFull(cr, buf[:padLen(version, int(cr.n))])
+	return err
+}
+
+// readBytes reads n bytes. Since n comes from the header, which may be
+// corrupt, the bytes are buffered as they arrive rather than all at once.
+func (cr *countingReader) readBytes(n int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(cr, int64(n)))
+	if err == nil && len(data) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	return data, err
+}
+
+// readUint32s reads n little-endian values into dst, if it is large enough,
+// using buf as scratch space. Otherwise, like readBytes, it grows the result
+// as the values arrive.
+func (cr *countingReader) readUint32s(dst []uint32, n int, buf []byte) ([]uint32, error) {
+	per := len(buf) / bphw
+	vs := dst[:0]
+	if cap(vs) < n {
+		vs = make([]uint32, 0, min(n, per))
+	}
+	for len(vs) < n {
+		chunk := min(per, n-len(vs))
+		if _, err := io.ReadFull(cr, buf[:chunk*bphw]); err != nil {
+			return nil, err
+		}
+		for i := 0; i < chunk; i++ {
+			vs = append(vs, binary.LittleEndian.Uint32(buf[i*bphw:]))
+		}
+	}
+	return vs, nil
+}
+
+// readUint16s is like readUint32s but for uint16s.
+func (cr *countingReader) readUint16s(dst []uint16, n int, buf []byte) ([]uint16, error) {
+	per := len(buf) / bpqw
+	vs := dst[:0]
+	if cap(vs) < n {
+		vs = make([]uint16, 0, min(n, per))
+	}
+	for len(vs) < n {
+		chunk := min(per, n-len(vs))
+		if _, err := io.ReadFull(cr, buf[:chunk*bpqw]); err != nil {
+			return nil, err
+		}
+		for i := 0; i < chunk; i++ {
+			vs = append(vs, binary.LittleEndian.Uint16(buf[i*bpqw:]))
+		}
+	}
+	return vs, nil
+}
+
+// readUvarints reads n values encoded by appendUvarints into dst, if it is
+// large enough. Values too large for E are rejected.
+func readUvarints[E uint16 | uint32](cr *countingReader, dst []E, n int) ([]E, error) {
+	vs := dst[:0]
+	if cap(vs) < n {
+		vs = make([]E, 0, min(n, streamBufSize))
+	}
+	for len(vs) < n {
+		v, err := binary.ReadUvarint(cr)
+		if err != nil {
+			return nil, err
+		}
+		if v > uint64(^E(0)) {
+			return nil, errors.New("mph.ReadFrom: bad compact level arrays")
+		}
+		vs = append(vs, E(v))
+	}
+	return vs, nil
+}
@@ -0,0 +1,290 @@
+package mph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+)
+
+func TestWriteToReadFrom(t *testing.T) {
+	var keys []string
+	for i := 0; i < 5000; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	plain, err := Build(keys, 1.0, 1e-9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withKeys, err := BuildWithKeys(keys, 1.0, 1e-9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []*Table{plain, withKeys} {
+		want, err := table.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		n, err := table.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+			t.Fatalf("WriteTo wrote %d bytes which differ from MarshalBinary (%d bytes)", n, len(want))
+		}
+
+		buf.WriteString("trailing")
+		var decoded Table
+		n, err = decoded.ReadFrom(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(want)) {
+			t.Errorf("ReadFrom: got n=%d; want %d", n, len(want))
+		}
+		if got := buf.String(); got != "trailing" {
+			t.Errorf("ReadFrom left %q unread; want %q", got, "trailing")
+		}
+		data, err := decoded.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Error("table read by ReadFrom differs from the original")
+		}
+	}
+}
+
+func TestDecodeFrom(t *testing.T) {
+	keys := make([]string, 5000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := BuildWithKeys(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got Table
+	if err := want.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(data)
+	if err := got.DecodeFrom(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 0 {
+		t.Errorf("DecodeFrom left %d bytes unread", r.Len())
+	}
+	if !got.Equal(&want) {
+		t.Error("DecodeFrom and UnmarshalBinary differ")
+	}
+	for i, key := range keys {
+		if n, ok := got.Lookup(key); !ok || n != uint32(i) {
+			t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+	if err := got.DecodeFrom(bytes.NewReader(data[:len(data)/2])); err != io.ErrUnexpectedEOF {
+		t.Errorf("DecodeFrom of truncated data: got %v; want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestReadFrom_v1(t *testing.T) {
+	keys := []string{"foo", "foo2", "bar", "baz", "quux"}
+	table, err := Build(keys, 1.0, 1e-9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if _, err := decoded.ReadFrom(bytes.NewReader(marshalV1(t, table))); !errors.Is(err, ErrNotTable) {
+		t.Fatalf("ReadFrom without AllowLegacyFormat: got err=%v; want one wrapping %v", err, ErrNotTable)
+	}
+	allowLegacyFormat(t)
+	if _, err := decoded.ReadFrom(bytes.NewReader(marshalV1(t, table))); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.Len(); got != len(keys) {
+		t.Errorf("Len: got %d; want %d", got, len(keys))
+	}
+	for i, key := range keys {
+		if n, ok := decoded.Lookup(key); !ok || int(n) != i {
+			t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+}
+
+func TestReadFrom_short(t *testing.T) {
+	table, err := BuildWithKeys([]string{"foo", "foo2", "bar", "baz", "quux"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Table
+	if _, err := decoded.ReadFrom(bytes.NewReader(nil)); err != io.EOF {
+		t.Errorf("ReadFrom(empty): got err=%v; want io.EOF", err)
+	}
+	for i := 1; i < len(data); i++ {
+		n, err := decoded.ReadFrom(bytes.NewReader(data[:i]))
+		if err != io.ErrUnexpectedEOF {
+			t.Fatalf("ReadFrom(%d of %d bytes): got err=%v; want io.ErrUnexpectedEOF",
+				i, len(data), err)
+		}
+		if n != int64(i) {
+			t.Fatalf("ReadFrom(%d of %d bytes): got n=%d; want %d", i, len(data), n, i)
+		}
+	}
+}
+
+// limitWriter fails once more than n bytes have been written.
+type limitWriter struct {
+	n int
+}
+
+var errLimit = errors.New("write limit reached")
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errLimit
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestWriteTo_error(t *testing.T) {
+	table, err := BuildWithKeys([]string{"foo", "foo2", "bar", "baz", "quux"}, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, limit := range []int{0, 10, len(data) / 2, len(data) - 1} {
+		n, err := table.WriteTo(&limitWriter{limit})
+		if err != errLimit {
+			t.Errorf("WriteTo with limit %d: got err=%v; want %v", limit, err, errLimit)
+		}
+		if n != int64(limit) {
+			t.Errorf("WriteTo with limit %d: got n=%d; want %d", limit, n, limit)
+		}
+	}
+}
+
+func TestWriteChunked(t *testing.T) {
+	var keys []string
+	for i := 0; i < 5000; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	plain, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// This table has every chunk.
+	full, err := NewBuilder(WithSlotFingerprint(8), WithNarrowSeeds()).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full.setFrontCodedKeys(keys)
+	for _, table := range []*Table{plain, full} {
+		var buf bytes.Buffer
+		n, err := table.WriteChunked(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(buf.Len()) {
+			t.Errorf("WriteChunked: got n=%d; wrote %d bytes", n, buf.Len())
+		}
+		data := bytes.Clone(buf.Bytes())
+		buf.WriteString("trailing")
+		var read, decoded Table
+		if n, err = read.ReadFrom(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(data)) || buf.String() != "trailing" {
+			t.Errorf("ReadFrom: got n=%d, leaving %q unread; want %d, leaving %q", n, buf.String(), len(data), "trailing")
+		}
+		if err := decoded.UnmarshalBinary(append(bytes.Clone(data), "trailing"...)); !errors.Is(err, ErrInvalidTable) {
+			t.Errorf("UnmarshalBinary with trailing bytes: got err=%v; want ErrInvalidTable", err)
+		}
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		for _, tbl := range []*Table{&read, &decoded} {
+			if !tbl.Equal(table) {
+				t.Error("decoded chunked table differs from the original")
+			}
+			if err := tbl.Verify(); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := full.WriteChunked(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// Find the middle of each chunk's bytes by following the framing.
+	middles := make(map[string]int)
+	off := headerLen(ver) + checksumLen
+	for _, name := range []string{"bloom", "level0", "level1", "fingerprints", "keys"} {
+		n := int(binary.LittleEndian.Uint64(data[off:]))
+		middles[name] = off + bpw + n/2
+		off += bpw + n + checksumLen
+	}
+	if off != len(data) {
+		t.Fatalf("chunks end at %d; want %d", off, len(data))
+	}
+	type corruption struct {
+		name  string
+		off   int
+		chunk string
+	}
+	corruptions := []corruption{
+		{"level0 length", wordOffset(ver, 1), "header"},
+		{"level1 length", wordOffset(ver, 2) + 3, "header"},
+		{"flags", wordOffset(ver, 4), "header"},
+		{"hasher ID", wordOffset(ver, 5) - 1, "header"},
+		{"false positive rate", wordOffset(ver, 6), "header"},
+		{"bucket seed", wordOffset(ver, 7), "header"},
+	}
+	for _, name := range []string{"bloom", "level0", "level1", "fingerprints", "keys"} {
+		corruptions = append(corruptions, corruption{name + " chunk", middles[name], name})
+	}
+	for _, c := range corruptions {
+		corrupt := bytes.Clone(data)
+		corrupt[c.off] ^= 0x10
+		var chunkErr *ChunkError
+		var decoded Table
+		if _, err := decoded.ReadFrom(bytes.NewReader(corrupt)); !errors.As(err, &chunkErr) || chunkErr.Chunk != c.chunk {
+			t.Errorf("ReadFrom with a corrupt %s: got err=%v; want a *ChunkError naming the %s chunk", c.name, err, c.chunk)
+		}
+		if err := decoded.UnmarshalBinary(corrupt); !errors.As(err, &chunkErr) || chunkErr.Chunk != c.chunk {
+			t.Errorf("UnmarshalBinary with a corrupt %s: got err=%v; want a *ChunkError naming the %s chunk", c.name, err, c.chunk)
+		}
+	}
+
+	var chunkErr *ChunkError
+	var decoded Table
+	_, err = decoded.ReadFrom(bytes.NewReader(data[:middles["level1"]]))
+	if !errors.As(err, &chunkErr) || chunkErr.Chunk != "level1" || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadFrom of a truncated level1 chunk: got err=%v; want a *ChunkError naming it and matching io.ErrUnexpectedEOF", err)
+	}
+	badLen := bytes.Clone(data)
+	badLen[headerLen(ver)+checksumLen]++
+	_, err = decoded.ReadFrom(bytes.NewReader(badLen))
+	if !errors.As(err, &chunkErr) || chunkErr.Chunk != "bloom" || !errors.Is(err, ErrInvalidTable) {
+		t.Errorf("ReadFrom with a wrong bloom chunk length: got err=%v; want a *ChunkError naming it and matching ErrInvalidTable", err)
+	}
+}
@@ -0,0 +1,218 @@
+package mph
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/instabid/bloom"
+)
+
+// A Table64 is like a Table but uses 64-bit key indices, so it can hold more
+// than math.MaxUint32 keys. To address that many slots, keys are placed in
+// level1 using a 64-bit hash formed from two 32-bit hashes. Like a Table, it
+// is safe for concurrent lookups.
+type Table64 struct {
+	filter    *bloom.Filter
+	hasher    Hasher // nil means Murmur3
+	reduction reduction
+	level0    []uint32
+	level0Len int
+	level1    []uint64
+	level1Len int
+	numKeys   int
+	fallback  bool
+	folding   folding
+
+	loadFactor float32
+	fpProb     float64
+	bucketSeed uint32
+}
+
+// Build64 is like Build but builds a Table64.
+func Build64(keys []string, loadFactor float32, fpProb float64) (*Table64, error) {
+	return NewBuilder(WithLoadFactor(loadFactor), WithFalsePositiveRate(fpProb)).Build64(keys)
+}
+
+func (b *Builder) buildInternal64(ctx context.Context, keys []string, loadFactor float32, filter *bloom.Filter, spent uint64) (*Table64, buildStats, error) {
+	level0, level1, stats, err := placeKeys[uint64](ctx, b, keys, loadFactor, true)
+	stats.totalSeedAttempts = spent + stats.seedAttempts
+	if level0 == nil {
+		return nil, stats, err
+	}
+	return &Table64{
+		filter:    filter,
+		hasher:    b.hasher,
+		reduction: b.reduction,
+		level0:    level0,
+		level0Len: len(level0),
+		level1:    level1,
+		level1Len: len(level1),
+		numKeys:   len(keys),
+		fallback:  b.fallback,
+		folding:   b.folding,
+
+		loadFactor: loadFactor,
+		fpProb:     b.filterFPProb(),
+		bucketSeed: b.bucketSeed,
+	}, stats, nil
+}
+
+// hash64 returns a 64-bit hash of s using h with two different seeds derived
+// from seed.
+func hash64(h Hasher, seed uint32, s string) uint64 {
+	return uint64(hashString(h, seed, s))<<32 | uint64(hashString(h, ^seed, s))
+}
+
+// Lookup searches for s in t and returns its index and whether it was found.
+// If t was built WithoutBloom, every s is reported as found, unless t has no
+// keys. Like a Table, a nil or zero Table64 holds no strings.
+func (t *Table64) Lookup(s string) (n uint64, ok bool) {
+	if t == nil || t.numKeys == 0 {
+		return 0, false
+	}
+	s = t.folding.apply(s)
+	h0 := hashString(t.hasher, t.bucketSeed, s)
+	seed := t.level0[t.reduction.index(h0, t.level0Len)]
+	if t.fallback && seed&fallbackBit != 0 {
+		n = t.level1[t.reduction.index(fallbackHash(t.hasher, h0, seed&^fallbackBit, s), t.level1Len)]
+	} else {
+		n = t.level1[t.reduction.index64(hash64(t.hasher, seed, s), t.level1Len)]
+	}
+	return n, t.filter == nil || t.filter.Has(s)
+}
+
+// Len returns the number of keys in t.
+func (t *Table64) Len() int {
+	return t.numKeys
+}
+
+// LoadFactor returns the load factor at which t was built, like
+// Table.LoadFactor.
+func (t *Table64) LoadFactor() float32 {
+	return t.loadFactor
+}
+
+func (t *Table64) MarshalBinary() ([]byte, error) {
+	bd, err := marshalFilter(t.filter)
+	if err != nil {
+		return nil, err
+	}
+	h := header{
+		version:   ver,
+		bloomLen:  len(bd),
+		level0Len: t.level0Len,
+		level1Len: t.level1Len,
+		numKeys:   t.numKeys,
+		flags:     flagWideIndex,
+
+		loadFactor: t.loadFactor,
+		fpProb:     t.fpProb,
+		bucketSeed: t.bucketSeed,
+	}
+	h.setHasher(t.hasher)
+	h.flags |= uint64(t.reduction) << reductionShift
+	if t.fallback {
+		h.flags |= flagFallback
+	}
+	h.flags |= t.folding.flags()
+	off0, off1, size := h.offsets(bpw)
+	data := make([]byte, size, size+checksumLen)
+	h.put(data)
+	copy(data[headerLen(ver):], bd)
+	for i, v := range t.level0 {
+		binary.LittleEndian.PutUint32(data[off0+i*bphw:], v)
+	}
+	for i, v := range t.level1 {
+		binary.LittleEndian.PutUint64(data[off1+i*bpw:], v)
+	}
+	data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+	return data, nil
+}
+
+// UnmarshalBinary decodes a Table64 encoded by MarshalBinary. Like
+// Table.UnmarshalBinary, it rejects data without the magic of the encoding
+// unless AllowLegacyFormat is set, and on error leaves t empty.
+func (t *Table64) UnmarshalBinary(data []byte) error {
+	*t = Table64{}
+	if err := checkLegacy(data); err != nil {
+		return err
+	}
+	return t.unmarshal(data)
+}
+
+// unmarshal is UnmarshalBinary without the check of checkLegacy.
+func (t *Table64) unmarshal(data []byte) error {
+	h, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+	if h.flags&flagWideIndex == 0 {
+		return errors.New("mph.UnmarshalBinary: table has 32-bit indices; use Table")
+	}
+	if h.flags&flagCompact != 0 {
+		return errors.New("mph.UnmarshalBinary: compact encoding of 64-bit indices is not supported")
+	}
+	if h.flags&flagNarrowSeeds != 0 {
+		return errors.New("mph.UnmarshalBinary: narrow seeds with 64-bit indices are not supported")
+	}
+	if h.flags&fingerprintWidth != 0 {
+		return errors.New("mph.UnmarshalBinary: fingerprints with 64-bit indices are not supported")
+	}
+	start := headerLen(h.version)
+	if len(data)-start < h.bloomLen {
+		return fmt.Errorf("%w for the bloom filter", ErrShortData)
+	}
+	off0, off1, size := h.offsets(bpw)
+	if len(data) < size {
+		return fmt.Errorf("%w for the level arrays", ErrShortData)
+	}
+	if h.version >= 4 {
+		if err := verifyChecksum(data, size); err != nil {
+			return err
+		}
+	}
+	hasher, err := h.hasher()
+	if err != nil {
+		return err
+	}
+	reduction, err := h.reduction()
+	if err != nil {
+		return err
+	}
+	folding, err := h.folding()
+	if err != nil {
+		return err
+	}
+	filter, err := unmarshalFilter(data[start : start+h.bloomLen])
+	if err != nil {
+		return err
+	}
+	level0 := decodeUint32s(nil, data[off0:], h.level0Len, false)
+	level1 := make([]uint64, h.level1Len)
+	for i := range level1 {
+		level1[i] = binary.LittleEndian.Uint64(data[off1+i*bpw:])
+	}
+	*t = Table64{
+		filter:    filter,
+		hasher:    hasher,
+		reduction: reduction,
+		level0:    level0,
+		level0Len: len(level0),
+		level1:    level1,
+		level1Len: len(level1),
+		numKeys:   h.numKeys,
+		fallback:  h.flags&flagFallback != 0,
+		folding:   folding,
+
+		loadFactor: h.loadFactor,
+		fpProb:     h.fpProb,
+		bucketSeed: h.bucketSeed,
+	}
+	if h.version < 6 {
+		t.loadFactor = estimateLoadFactor(t.numKeys, t.level1Len)
+	}
+	return nil
+}
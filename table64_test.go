@@ -0,0 +1,71 @@
+package mph
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestBuild64(t *testing.T) {
+	var keys, extra []string
+	for i := 0; i < 20000; i++ {
+		s := strconv.Itoa(i)
+		if i < 10000 {
+			keys = append(keys, s)
+		} else {
+			extra = append(extra, s)
+		}
+	}
+	table, err := Build64(keys, 1.0, 1e-9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := headerLen(ver) + table.level0Len*4 + table.level1Len*8; len(data) < want {
+		t.Errorf("len(MarshalBinary()): got %d; want at least %d", len(data), want)
+	}
+	var decoded Table64
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range []*Table64{table, &decoded} {
+		if got := tbl.Len(); got != len(keys) {
+			t.Errorf("Len: got %d; want %d", got, len(keys))
+		}
+		for i, key := range keys {
+			if n, ok := tbl.Lookup(key); !ok || n != uint64(i) {
+				t.Errorf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+			}
+		}
+		for _, key := range extra {
+			if _, ok := tbl.Lookup(key); ok {
+				t.Errorf("Lookup(%s): got ok; want !ok", key)
+			}
+		}
+	}
+
+	// Each table type rejects the other's encoding.
+	var table32 Table
+	if err := table32.UnmarshalBinary(data); err == nil {
+		t.Error("Table.UnmarshalBinary of a Table64: got nil error")
+	}
+	small, err := Build(keys[:10], 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = small.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.UnmarshalBinary(data); err == nil {
+		t.Error("Table64.UnmarshalBinary of a Table: got nil error")
+	}
+	// A failed decode leaves the table empty rather than holding the keys
+	// decoded into it before.
+	if _, ok := decoded.Lookup(keys[0]); ok || decoded.Len() != 0 {
+		t.Errorf("after a failed UnmarshalBinary: got Len %d and Lookup(%s) ok=%t; want 0 and false",
+			decoded.Len(), keys[0], ok)
+	}
+}
@@ -0,0 +1,31 @@
+package mph
+
+// Upgrade decodes a table encoded in any supported version, with 32-bit or
+// 64-bit indices, and returns its encoding in FormatVersion, which is
+// compact if the original is. It accepts the versions before the magic was
+// introduced whether or not AllowLegacyFormat is set. It is much cheaper
+// than rebuilding the table from its keys. Details which older versions did
+// not record stay unknown: a load factor missing before version 6 is
+// recorded as estimated by UnmarshalBinary, and a false positive rate
+// missing before version 7 as 0.
+func Upgrade(data []byte) ([]byte, error) {
+	h, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if h.flags&flagWideIndex != 0 {
+		var t Table64
+		if err := t.unmarshal(data); err != nil {
+			return nil, err
+		}
+		return t.MarshalBinary()
+	}
+	var t Table
+	if err := t.unmarshal(data, false); err != nil {
+		return nil, err
+	}
+	if h.flags&flagCompact != 0 {
+		return t.MarshalCompact()
+	}
+	return t.MarshalBinary()
+}
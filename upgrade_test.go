@@ -0,0 +1,85 @@
+package mph
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+func TestUpgrade(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := marshalV1(t, table)
+	data, err := Upgrade(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := FormatVersionOf(data); err != nil || v != FormatVersion {
+		t.Errorf("FormatVersionOf(Upgrade(v1)): got (%d, %v); want (%d, nil)", v, err, FormatVersion)
+	}
+	var decodedOld, upgraded Table
+	allowLegacyFormat(t)
+	if err := decodedOld.UnmarshalBinary(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := upgraded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !upgraded.Equal(&decodedOld) || !upgraded.Equal(table) {
+		t.Error("Upgrade(v1): got a different table")
+	}
+	if upgraded.Len() != len(keys) {
+		t.Errorf("Len: got %d; want %d", upgraded.Len(), len(keys))
+	}
+	oldNs, _ := decodedOld.LookupAll(keys)
+	for i, key := range keys {
+		if n, ok := upgraded.Lookup(key); !ok || n != oldNs[i] || n != uint32(i) {
+			t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+
+	// Tables already in the current version are unchanged.
+	current, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = Upgrade(current); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, current) {
+		t.Error("Upgrade of the current version changed the encoding")
+	}
+	compact, err := table.MarshalCompact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = Upgrade(compact); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, compact) {
+		t.Error("Upgrade of a compact encoding changed it")
+	}
+	table64, err := Build64(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current, err = table64.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = Upgrade(current); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, current) {
+		t.Error("Upgrade of a Table64 changed the encoding")
+	}
+
+	if _, err := Upgrade(old[:len(old)-1]); err == nil {
+		t.Error("Upgrade of truncated data: got nil error")
+	}
+}
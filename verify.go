@@ -0,0 +1,140 @@
+package mph
+
+import "fmt"
+
+// Verify checks that t is internally consistent, which is worth doing for a
+// table decoded from an untrusted source before using it. It checks the sizes
+// of the level arrays, that every stored index refers to a key and every key
+// has a slot, and, for tables with stored keys, that every stored key is
+// found at its own index. For tables built with BuildWithIndices, whose
+// indices are the caller's, only the sizes are checked. It returns an error
+// wrapping ErrInvalidTable which describes the first inconsistency found;
+// VerifyKeys finds every stored key which is misplaced.
+func (t *Table) Verify() error {
+	if err := t.verifyLevels(); err != nil {
+		return err
+	}
+	if t.ids {
+		return nil // level1 holds arbitrary indices, and no keys are stored
+	}
+	placed := make([]bool, t.numKeys)
+	for i, n := range t.level1 {
+		if int64(n) >= int64(t.numKeys) {
+			if t.numKeys == 0 && n == 0 {
+				continue // the single slot of an empty table
+			}
+			return fmt.Errorf("%w: level1[%d] is %d, not the index of one of the %d keys",
+				ErrInvalidTable, i, n, t.numKeys)
+		}
+		placed[n] = true
+	}
+	for n, ok := range placed {
+		if !ok {
+			return fmt.Errorf("%w: key %d has no level1 slot", ErrInvalidTable, n)
+		}
+	}
+	if t.keyEnds == nil {
+		return nil
+	}
+	return t.eachStoredKey(func(n int, key string) error {
+		if got := t.index(key); got != uint32(n) {
+			return fmt.Errorf("%w: stored key %d (%q) hashes to index %d",
+				ErrInvalidTable, n, key, got)
+		}
+		if !t.Contains(key) {
+			where := "the bloom filter"
+			if t.fingerprints != nil {
+				where = "the fingerprints"
+			}
+			return fmt.Errorf("%w: stored key %d (%q) is missing from %s",
+				ErrInvalidTable, n, key, where)
+		}
+		return nil
+	})
+}
+
+// VerifyKeys follows the path of each of t's stored keys through its level0
+// bucket's seed to a level1 slot and returns, in increasing order, the keys
+// whose slot doesn't hold their own index. Unlike Verify, which stops at the
+// first inconsistency, it finds every key affected by a corrupted seed or
+// slot. It returns ErrNoKeys if t has no stored keys, and an error wrapping
+// ErrInvalidTable if the sizes of t's arrays or its stored keys are
+// inconsistent, since no path can be followed then.
+func (t *Table) VerifyKeys() ([]uint32, error) {
+	if t.keyEnds == nil {
+		return nil, ErrNoKeys
+	}
+	if err := t.verifyLevels(); err != nil {
+		return nil, err
+	}
+	var wrong []uint32
+	err := t.eachStoredKey(func(n int, key string) error {
+		if t.index(key) != uint32(n) {
+			wrong = append(wrong, uint32(n))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wrong, nil
+}
+
+// verifyLevels checks the sizes of t's level arrays and fingerprints.
+func (t *Table) verifyLevels() error {
+	switch {
+	case t.level0Len < 1 || len(t.level0)+len(t.level0u16) != t.level0Len:
+		return fmt.Errorf("%w: %d level0 buckets, want %d and at least 1",
+			ErrInvalidTable, len(t.level0)+len(t.level0u16), t.level0Len)
+	case t.level1Len < 1 || len(t.level1) != t.level1Len:
+		return fmt.Errorf("%w: %d level1 slots, want %d and at least 1",
+			ErrInvalidTable, len(t.level1), t.level1Len)
+	case t.numKeys < 0 || t.numKeys > t.level1Len:
+		return fmt.Errorf("%w: %d keys in %d level1 slots",
+			ErrInvalidTable, t.numKeys, t.level1Len)
+	}
+	if err := t.reduction.check(t.level0Len, t.level1Len); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTable, err)
+	}
+	if t.fingerprints != nil && len(t.fingerprints) != t.level1Len*t.fingerprintBits/8 {
+		return fmt.Errorf("%w: %d bytes of %d-bit fingerprints for %d level1 slots",
+			ErrInvalidTable, len(t.fingerprints), t.fingerprintBits, t.level1Len)
+	}
+	return nil
+}
+
+// eachStoredKey calls f with each of t's stored keys and its index, checking
+// their layout as it goes, and returns the first error, from f or wrapping
+// ErrInvalidTable.
+func (t *Table) eachStoredKey(f func(n int, key string) error) error {
+	if len(t.keyEnds) != t.numKeys {
+		return fmt.Errorf("%w: %d stored keys, want %d", ErrInvalidTable, len(t.keyEnds), t.numKeys)
+	}
+	if t.keyPrefixes != nil && len(t.keyPrefixes) != t.numKeys {
+		return fmt.Errorf("%w: %d stored key prefixes, want %d", ErrInvalidTable, len(t.keyPrefixes), t.numKeys)
+	}
+	var (
+		start int
+		prev  string
+	)
+	for n, end := range t.keyEnds {
+		if end < start || end > len(t.keyData) {
+			return fmt.Errorf("%w: stored key %d ends at %d, outside [%d, %d]",
+				ErrInvalidTable, n, end, start, len(t.keyData))
+		}
+		key := t.keyData[start:end]
+		if t.keyPrefixes != nil {
+			if p := t.keyPrefixes[n]; n%frontBlock == 0 && p != 0 || p > len(prev) {
+				return fmt.Errorf("%w: stored key %d shares %d bytes with the previous key, of %d",
+					ErrInvalidTable, n, p, len(prev))
+			}
+			key = prev[:t.keyPrefixes[n]] + key
+			prev = key
+		}
+		if err := f(n, key); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package mph
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, tt := range []struct {
+		name    string
+		corrupt func(*Table)
+	}{
+		{"truncated level0", func(t *Table) { t.level0 = t.level0[:t.level0Len-1] }},
+		{"truncated level1", func(t *Table) { t.level1 = t.level1[:t.level1Len-1] }},
+		{"index out of range", func(t *Table) { t.level1[0] = uint32(t.numKeys) }},
+		{"missing index", func(t *Table) {
+			for i, n := range t.level1 {
+				if n == 7 {
+					t.level1[i] = 8
+				}
+			}
+		}},
+		{"swapped slots", func(t *Table) { t.level1[0], t.level1[1] = t.level1[1], t.level1[0] }},
+		{"wrong seed", func(t *Table) { t.level0[0]++ }},
+		{"short key data", func(t *Table) { t.keyData = t.keyData[:len(t.keyData)-1] }},
+	} {
+		table, err := BuildWithKeys(keys, 1.0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := table.Verify(); err != nil {
+			t.Fatalf("Verify before corrupting the table: %v", err)
+		}
+		tt.corrupt(table)
+		if err := table.Verify(); !errors.Is(err, ErrInvalidTable) {
+			t.Errorf("Verify with %s: got err=%v; want one wrapping %v", tt.name, err, ErrInvalidTable)
+		}
+	}
+
+	for _, keys := range [][]string{nil, {"a"}, keys} {
+		table, err := Build(keys, 1.0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := table.Verify(); err != nil {
+			t.Errorf("Verify of table of %d keys: %v", len(keys), err)
+		}
+	}
+}
+
+func TestVerifyKeys(t *testing.T) {
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	for _, build := range []func([]string, float32, float64) (*Table, error){BuildWithKeys, BuildWithFrontCodedKeys} {
+		table, err := build(keys, 1.0, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wrong, err := table.VerifyKeys(); err != nil || wrong != nil {
+			t.Fatalf("VerifyKeys before corrupting the table: got (%v, %v); want (nil, nil)", wrong, err)
+		}
+
+		// Changing the seed of key 42's bucket misplaces all of its keys,
+		// and only those.
+		bucket := table.reduction.index(table.Hash("42"), table.level0Len)
+		var want []uint32
+		for i, key := range keys {
+			if table.reduction.index(table.Hash(key), table.level0Len) == bucket {
+				want = append(want, uint32(i))
+			}
+		}
+		table.level0[bucket]++
+		wrong, err := table.VerifyKeys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(wrong, want) {
+			t.Errorf("VerifyKeys with a wrong seed: got %v; want the keys of its bucket, %v", wrong, want)
+		}
+
+		table.keyData = table.keyData[:len(table.keyData)-1]
+		if _, err := table.VerifyKeys(); !errors.Is(err, ErrInvalidTable) {
+			t.Errorf("VerifyKeys with short key data: got err=%v; want one wrapping %v", err, ErrInvalidTable)
+		}
+	}
+
+	table, err := Build(keys, 1.0, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.VerifyKeys(); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("VerifyKeys without stored keys: got err=%v; want %v", err, ErrNoKeys)
+	}
+}
@@ -0,0 +1,71 @@
+package mph
+
+import "math/bits"
+
+// This file contains the 32-bit xxHash function, XXH32. See
+// https://github.com/Cyan4973/xxHash/blob/dev/doc/xxhash_spec.md. Like
+// Murmur3, it reads its input as little-endian blocks, so its hashes are the
+// same on every platform.
+
+// XXHash is a Hasher using the 32-bit xxHash function, which processes four
+// independent 32-bit lanes at a time and so is faster than Murmur3 for long
+// keys. Tables built WithHasher(XXHash{}) record it, so no registration is
+// needed to decode them.
+type XXHash struct{}
+
+func (XXHash) Hash(seed uint32, data []byte) uint32 {
+	return xxh32(seed, unsafeString(data))
+}
+
+func (XXHash) ID() byte { return 1 }
+
+const (
+	xxPrime1 = 0x9e3779b1
+	xxPrime2 = 0x85ebca77
+	xxPrime3 = 0xc2b2ae3d
+	xxPrime4 = 0x27d4eb2f
+	xxPrime5 = 0x165667b1
+)
+
+// xxh32 computes the XXH32 hash of s using the given seed.
+func xxh32(seed uint32, s string) uint32 {
+	l := len(s)
+	var h uint32
+	if l >= 16 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+		for ; len(s) >= 16; s = s[16:] {
+			v1 = xxRound(v1, block(s[0:4]))
+			v2 = xxRound(v2, block(s[4:8]))
+			v3 = xxRound(v3, block(s[8:12]))
+			v4 = xxRound(v4, block(s[12:16]))
+		}
+		h = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) +
+			bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		h = seed + xxPrime5
+	}
+	h += uint32(l)
+	for ; len(s) >= 4; s = s[4:] {
+		h += block(s[0:4]) * xxPrime3
+		h = bits.RotateLeft32(h, 17) * xxPrime4
+	}
+	for i := 0; i < len(s); i++ {
+		h += uint32(s[i]) * xxPrime5
+		h = bits.RotateLeft32(h, 11) * xxPrime1
+	}
+	h ^= h >> 15
+	h *= xxPrime2
+	h ^= h >> 13
+	h *= xxPrime3
+	h ^= h >> 16
+	return h
+}
+
+// xxRound mixes a 4-byte lane of input into the accumulator acc.
+func xxRound(acc, lane uint32) uint32 {
+	acc += lane * xxPrime2
+	return bits.RotateLeft32(acc, 13) * xxPrime1
+}
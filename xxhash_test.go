@@ -0,0 +1,107 @@
+package mph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestXXHash(t *testing.T) {
+	tests := []struct {
+		input string
+		seed  uint32
+		want  uint32
+	}{
+		{"", 0, 0x02cc5d05},
+		{"a", 0, 0x550d7456},
+		{"abc", 0, 0x32d153ff},
+		{"Nobody inspects the spammish repetition", 0, 0xe2293b2f},
+	}
+	for _, tt := range tests {
+		if got := (XXHash{}).Hash(tt.seed, []byte(tt.input)); got != tt.want {
+			t.Errorf("XXHash(%q, seed=0x%x): got 0x%x; want 0x%x", tt.input, tt.seed, got, tt.want)
+		}
+	}
+}
+
+func TestWithHasher_xxhash(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	table, err := NewBuilder(WithHasher(XXHash{})).Build(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := table.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := parseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := byte(h.flags >> hasherShift); id != (XXHash{}).ID() {
+		t.Errorf("encoded hasher ID: got %d; want %d", id, XXHash{}.ID())
+	}
+	var decoded Table
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decoded.hasher.(XXHash); !ok {
+		t.Errorf("decoded hasher: got %T; want XXHash", decoded.hasher)
+	}
+	if !decoded.Equal(table) {
+		t.Error("decoded table differs from the encoded one")
+	}
+	if err := decoded.Verify(); err != nil {
+		t.Error(err)
+	}
+	for i, key := range keys {
+		if n, ok := decoded.Lookup(key); !ok || n != uint32(i) {
+			t.Fatalf("Lookup(%s): got (%d, %t); want (%d, true)", key, n, ok, i)
+		}
+	}
+}
+
+func BenchmarkXXHash1(b *testing.B)   { benchmarkXXHash(b, 1) }
+func BenchmarkXXHash16(b *testing.B)  { benchmarkXXHash(b, 16) }
+func BenchmarkXXHash64(b *testing.B)  { benchmarkXXHash(b, 64) }
+func BenchmarkXXHash500(b *testing.B) { benchmarkXXHash(b, 500) }
+
+func benchmarkXXHash(b *testing.B, size int) {
+	s := strings.Repeat("a", size)
+	b.SetBytes(int64(size))
+	for i := 0; i < b.N; i++ {
+		xxh32(0, s)
+	}
+}
+
+// BenchmarkHashers compares building from, and looking up, long keys with
+// each of the Hashers provided by this package.
+func BenchmarkHashers(b *testing.B) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%0256d", i)
+	}
+	for _, h := range []Hasher{Murmur3{}, XXHash{}} {
+		builder := NewBuilder(WithHasher(h))
+		b.Run(fmt.Sprintf("%T/build", h), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := builder.Build(keys); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		table, err := builder.Build(keys)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("%T/lookup", h), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				table.Lookup(keys[i%len(keys)])
+			}
+		})
+	}
+}